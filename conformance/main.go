@@ -0,0 +1,123 @@
+// Command conformance runs every program in a directory through both
+// execution backends and fails when either of them differs from what the
+// program is expected to print, return or fail with. The expected output of
+// program.lang is kept next to it in program.out.
+//
+//	go run ./conformance [-update] [directory]
+//
+// -update writes the .out files from what the programs do now, provided both
+// backends agree. The same programs run under go test.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mutex "github.com/caelondev/mutex/src"
+	"github.com/caelondev/mutex/src/errors"
+)
+
+func main() {
+	update := flag.Bool("update", false, "write the expected output of every program from what it does now")
+	flag.Parse()
+
+	dir := "conformance/programs"
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	// Programs run from their directory, so the paths they print are the
+	// same wherever the suite is run from ---
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	paths, err := programs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v in %s\n", err, dir)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		tree := run(path, mutex.TreeWalker)
+		vm := run(path, mutex.BytecodeVM)
+
+		if *update {
+			if tree != vm {
+				failed++
+				fmt.Printf("FAIL  %s\n--- tree\n%s--- vm\n%s", filepath.Join(dir, path), tree, vm)
+				continue
+			}
+			if err := os.WriteFile(expectedPath(path), []byte(tree), 0o644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("wrote %s\n", filepath.Join(dir, expectedPath(path)))
+			continue
+		}
+
+		expected, err := os.ReadFile(expectedPath(path))
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL  %s\n%v\n", filepath.Join(dir, path), err)
+			continue
+		}
+
+		if tree == string(expected) && vm == string(expected) {
+			fmt.Printf("ok    %s\n", filepath.Join(dir, path))
+			continue
+		}
+
+		failed++
+		fmt.Printf("FAIL  %s\n--- expected\n%s--- tree\n%s--- vm\n%s", filepath.Join(dir, path), expected, tree, vm)
+	}
+
+	fmt.Printf("\n%d programs, %d failed\n", len(paths), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// programs returns the paths of the programs in the working directory.
+func programs() ([]string, error) {
+	paths, err := filepath.Glob("*.lang")
+	if err != nil || len(paths) == 0 {
+		return nil, fmt.Errorf("No programs found")
+	}
+	return paths, nil
+}
+
+// expectedPath returns the path of the file holding what the program at path
+// is expected to do.
+func expectedPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".out"
+}
+
+// stepLimit stops programs that never finish, and is what the programs that
+// test the limit run into.
+const stepLimit = 1000000
+
+// run executes the program at path on backend and describes everything it
+// did: its output, then its result or error.
+func run(path string, backend mutex.Backend) string {
+	var stdout bytes.Buffer
+	interpreter := mutex.NewInterpreter(mutex.WithStdout(&stdout), mutex.WithBackend(backend), mutex.WithStdin(nil), mutex.WithStepLimit(stepLimit))
+
+	result, err := interpreter.RunFile(path)
+	if err != nil {
+		fmt.Fprintf(&stdout, "error: %v\n", err)
+		if e, ok := err.(*errors.RuntimeError); ok {
+			fmt.Fprintf(&stdout, "%s\n", e.Traceback())
+		}
+	} else {
+		fmt.Fprintf(&stdout, "result: %v\n", result)
+	}
+
+	return stdout.String()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	mutex "github.com/caelondev/mutex/src"
+)
+
+// backends are the execution backends every program runs on, by name.
+var backends = map[string]mutex.Backend{
+	"tree": mutex.TreeWalker,
+	"vm":   mutex.BytecodeVM,
+}
+
+// TestPrograms runs every program on both backends and compares what each
+// does with the program's .out file.
+func TestPrograms(t *testing.T) {
+	t.Chdir("programs")
+
+	paths, err := programs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		expected, err := os.ReadFile(expectedPath(path))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+
+		for name, backend := range backends {
+			t.Run(path+"/"+name, func(t *testing.T) {
+				if got := run(path, backend); got != string(expected) {
+					t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+				}
+			})
+		}
+	}
+}
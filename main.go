@@ -3,5 +3,5 @@ package main
 import "github.com/caelondev/mutex/src"
 
 func main() {
-	src.Main()
+	mutex.Main()
 }
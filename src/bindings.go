@@ -0,0 +1,504 @@
+package mutex
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/runtime"
+)
+
+// Register makes the Go function fn callable from scripts as the constant
+// global name. Arguments are converted to fn's parameter types with
+// FromValue and its result back with ToValue. fn may return nothing, a
+// value, an error, or a value and an error; a non-nil error fails the call
+// with a runtime error scripts can catch. name may replace a built-in
+// function, but no other global.
+func (i *Interpreter) Register(name string, fn any) error {
+	function := reflect.ValueOf(fn)
+	if function.Kind() != reflect.Func {
+		return fmt.Errorf("cannot register %q: expected a function, got %T", name, fn)
+	}
+	if err := checkResults(function.Type()); err != nil {
+		return fmt.Errorf("cannot register %q: %v", name, err)
+	}
+
+	return i.declare(name, nativeFunction(name, function))
+}
+
+// Define makes value, converted with ToValue, available to scripts as the
+// constant global name. Like Register, it may replace a built-in.
+func (i *Interpreter) Define(name string, value any) error {
+	converted, err := ToValue(value)
+	if err != nil {
+		return fmt.Errorf("cannot define %q: %v", name, err)
+	}
+
+	return i.declare(name, converted)
+}
+
+func (i *Interpreter) declare(name string, value runtime.RuntimeValue) error {
+	if i.env.IsDeclared(name) && !i.env.IsNative(name) {
+		return fmt.Errorf("cannot define %q: it is already defined", name)
+	}
+
+	i.env.DeclareVariable(name, value, true)
+	return nil
+}
+
+// Call calls the function declared as the global name, such as a function
+// the interpreter's scripts declared at their top level, with args converted
+// by ToValue.
+func (i *Interpreter) Call(name string, args ...any) (runtime.RuntimeValue, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext calls a function like Call, stopping it like EvalContext once
+// ctx is done.
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...any) (result runtime.RuntimeValue, err error) {
+	if !i.env.IsDeclared(name) {
+		return nil, fmt.Errorf("cannot call %q: no global of that name is defined", name)
+	}
+
+	values := make([]runtime.RuntimeValue, len(args))
+	for index, arg := range args {
+		values[index], err = ToValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot call %q: argument %d: %v", name, index+1, err)
+		}
+	}
+
+	defer errors.Recover(&err)
+
+	i.env.Interpreter().Begin(ctx)
+	defer i.env.Interpreter().End()
+
+	return runtime.CallValue(i.env.GetVariable(name), values, i.env), nil
+}
+
+// CallAs calls a function like Call and converts its result to T with
+// FromValue.
+func CallAs[T any](i *Interpreter, name string, args ...any) (T, error) {
+	result, err := i.Call(name, args...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return FromValue[T](result)
+}
+
+// Conversion ---
+
+var (
+	runtimeValueType = reflect.TypeFor[runtime.RuntimeValue]()
+	errorType        = reflect.TypeFor[error]()
+)
+
+// ToValue converts a Go value to the Mutex value scripts see: booleans,
+// numbers of any kind and strings to their Mutex counterparts, slices to
+// arrays, maps with string keys to maps with their keys sorted, functions to
+// native functions, and nil to nil. Runtime values are passed on unchanged.
+func ToValue(value any) (runtime.RuntimeValue, error) {
+	return toValue(reflect.ValueOf(value), nil)
+}
+
+// reference identifies a Go slice, map or pointer, so a value that contains
+// itself is caught instead of converted forever.
+type reference struct {
+	pointer uintptr
+	length  int
+	t       reflect.Type
+}
+
+// toValue converts value, which the slices, maps and pointers in active
+// contain.
+func toValue(value reflect.Value, active []reference) (runtime.RuntimeValue, error) {
+	if !value.IsValid() {
+		return runtime.NIL(), nil
+	}
+
+	if value.Type().Implements(runtimeValueType) && !isNil(value) {
+		return value.Interface().(runtime.RuntimeValue), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		return runtime.BOOLEAN(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return runtime.NUMBER(float64(value.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return runtime.NUMBER(float64(value.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return runtime.NUMBER(value.Float()), nil
+	case reflect.String:
+		return &runtime.StringValue{Value: value.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice {
+			if value.IsNil() {
+				return runtime.NIL(), nil
+			}
+
+			var err error
+			if active, err = enter(active, value); err != nil {
+				return nil, err
+			}
+		}
+
+		elements := make([]runtime.RuntimeValue, value.Len())
+		for index := range elements {
+			element, err := toValue(value.Index(index), active)
+			if err != nil {
+				return nil, err
+			}
+			elements[index] = element
+		}
+		return runtime.ARRAY(elements), nil
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert a Go %s, map keys must be strings", value.Type())
+		}
+		if value.IsNil() {
+			return runtime.NIL(), nil
+		}
+
+		active, err := enter(active, value)
+		if err != nil {
+			return nil, err
+		}
+
+		// Go maps have no order, so keys are sorted to keep scripts deterministic ---
+		keys := value.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		})
+
+		object := runtime.MAP()
+		for _, key := range keys {
+			entry, err := toValue(value.MapIndex(key), active)
+			if err != nil {
+				return nil, err
+			}
+			object.Set(key.String(), entry)
+		}
+		return object, nil
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return runtime.NIL(), nil
+		}
+
+		if value.Kind() == reflect.Pointer {
+			var err error
+			if active, err = enter(active, value); err != nil {
+				return nil, err
+			}
+		}
+		return toValue(value.Elem(), active)
+	case reflect.Func:
+		if value.IsNil() {
+			return runtime.NIL(), nil
+		}
+		if err := checkResults(value.Type()); err != nil {
+			return nil, fmt.Errorf("cannot convert a Go %s: %v", value.Type(), err)
+		}
+		return nativeFunction("anonymous", value), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert a Go %s", value.Type())
+}
+
+// enter adds the slice, map or pointer value to active, failing if it is
+// already there, as value would then contain itself.
+func enter(active []reference, value reflect.Value) ([]reference, error) {
+	ref := reference{pointer: value.Pointer(), t: value.Type()}
+	if value.Kind() == reflect.Slice {
+		ref.length = value.Len()
+	}
+
+	if slices.Contains(active, ref) {
+		return nil, fmt.Errorf("cannot convert a Go %s that contains itself", value.Type())
+	}
+	return append(active, ref), nil
+}
+
+// FromValue converts a Mutex value to the Go type T. Numbers convert to any
+// Go number type they fit in exactly, arrays to slices, maps to maps with
+// string keys, and nil to the zero value of pointers, slices, maps and
+// interfaces. Converted to any, values become what encoding/json would
+// produce: nil, bool, float64, string, []any or map[string]any; values
+// without such a counterpart, like functions, stay runtime values.
+func FromValue[T any](value runtime.RuntimeValue) (T, error) {
+	var result T
+
+	converted, err := fromValue(value, reflect.TypeFor[T](), nil)
+	if err != nil {
+		return result, err
+	}
+
+	if converted.IsValid() {
+		result = converted.Interface().(T)
+	}
+	return result, nil
+}
+
+// fromValue converts value to t, inside the arrays and maps in active.
+func fromValue(value runtime.RuntimeValue, t reflect.Type, active []runtime.RuntimeValue) (reflect.Value, error) {
+	if _, isNil := value.(*runtime.NilValue); isNil {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	if t.Kind() == reflect.Interface {
+		if t.NumMethod() == 0 {
+			result, err := natural(value, active)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(result).Convert(t), nil
+		}
+		if reflect.TypeOf(value).Implements(t) {
+			return reflect.ValueOf(value).Convert(t), nil
+		}
+		return reflect.Value{}, mismatch(value, t)
+	}
+
+	converted := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Bool:
+		boolean, ok := value.(*runtime.BooleanValue)
+		if !ok {
+			return reflect.Value{}, mismatch(value, t)
+		}
+		converted.SetBool(boolean.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := integer(value, t)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		// Checked as a float first, as converting a larger one is undefined ---
+		if number < -(1<<63) || number >= 1<<63 || converted.OverflowInt(int64(number)) {
+			return reflect.Value{}, fmt.Errorf("expected %s, got %v which is out of range", typeName(t), number)
+		}
+		converted.SetInt(int64(number))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		number, err := integer(value, t)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if number < 0 || number >= 1<<64 || converted.OverflowUint(uint64(number)) {
+			return reflect.Value{}, fmt.Errorf("expected %s, got %v which is out of range", typeName(t), number)
+		}
+		converted.SetUint(uint64(number))
+	case reflect.Float32, reflect.Float64:
+		number, ok := value.(*runtime.NumberValue)
+		if !ok {
+			return reflect.Value{}, mismatch(value, t)
+		}
+		converted.SetFloat(number.Value)
+	case reflect.String:
+		str, ok := value.(*runtime.StringValue)
+		if !ok {
+			return reflect.Value{}, mismatch(value, t)
+		}
+		converted.SetString(str.Value)
+	case reflect.Slice:
+		array, ok := value.(*runtime.ArrayValue)
+		if !ok {
+			return reflect.Value{}, mismatch(value, t)
+		}
+
+		active, err := enterValue(active, array)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		converted.Set(reflect.MakeSlice(t, len(array.Elements), len(array.Elements)))
+		for index, element := range array.Elements {
+			item, err := fromValue(element, t.Elem(), active)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", index, err)
+			}
+			converted.Index(index).Set(item)
+		}
+	case reflect.Map:
+		object, ok := value.(*runtime.MapValue)
+		if !ok || t.Key().Kind() != reflect.String {
+			return reflect.Value{}, mismatch(value, t)
+		}
+
+		active, err := enterValue(active, object)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		converted.Set(reflect.MakeMapWithSize(t, len(object.Keys)))
+		for _, key := range object.Keys {
+			entry, err := fromValue(object.Entries[key], t.Elem(), active)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %q: %v", key, err)
+			}
+			converted.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), entry)
+		}
+	default:
+		return reflect.Value{}, mismatch(value, t)
+	}
+
+	return converted, nil
+}
+
+// natural returns the Go value a Mutex value converts to as an any, inside
+// the arrays and maps in active.
+func natural(value runtime.RuntimeValue, active []runtime.RuntimeValue) (any, error) {
+	switch v := value.(type) {
+	case *runtime.NilValue:
+		return nil, nil
+	case *runtime.BooleanValue:
+		return v.Value, nil
+	case *runtime.NumberValue:
+		return v.Value, nil
+	case *runtime.StringValue:
+		return v.Value, nil
+	case *runtime.ArrayValue:
+		active, err := enterValue(active, v)
+		if err != nil {
+			return nil, err
+		}
+
+		elements := make([]any, len(v.Elements))
+		for index, element := range v.Elements {
+			if elements[index], err = natural(element, active); err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+	case *runtime.MapValue:
+		active, err := enterValue(active, v)
+		if err != nil {
+			return nil, err
+		}
+
+		object := make(map[string]any, len(v.Keys))
+		for _, key := range v.Keys {
+			if object[key], err = natural(v.Entries[key], active); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	}
+	return value, nil
+}
+
+// enterValue adds the array or map value to active, failing if it is already
+// there, as value would then contain itself.
+func enterValue(active []runtime.RuntimeValue, value runtime.RuntimeValue) ([]runtime.RuntimeValue, error) {
+	if slices.Contains(active, value) {
+		return nil, fmt.Errorf("cannot convert a '%s' that contains itself", value.Type())
+	}
+	return append(active, value), nil
+}
+
+func integer(value runtime.RuntimeValue, t reflect.Type) (float64, error) {
+	number, ok := value.(*runtime.NumberValue)
+	if !ok {
+		return 0, mismatch(value, t)
+	}
+	if number.Value != math.Trunc(number.Value) || math.IsInf(number.Value, 0) {
+		return 0, fmt.Errorf("expected %s, got %v which is not a whole number", typeName(t), number.Value)
+	}
+	return number.Value, nil
+}
+
+func mismatch(value runtime.RuntimeValue, t reflect.Type) error {
+	return fmt.Errorf("expected %s, got '%s'", typeName(t), value.Type())
+}
+
+// typeName names t the way Go code spells it.
+func typeName(t reflect.Type) string {
+	return strings.ReplaceAll(t.String(), "interface {}", "any")
+}
+
+// Native functions ---
+
+// nativeFunction wraps the Go function fn, whose results checkResults
+// accepted, as a native function scripts call as name.
+func nativeFunction(name string, fn reflect.Value) *runtime.NativeFunctionValue {
+	t := fn.Type()
+
+	return runtime.NATIVE_FUNCTION(name, func(args []runtime.RuntimeValue, env runtime.Environment) runtime.RuntimeValue {
+		parameters := t.NumIn()
+		if t.IsVariadic() {
+			if len(args) < parameters-1 {
+				errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects at least %d arguments but got %d", name, parameters-1, len(args)))
+			}
+		} else if len(args) != parameters {
+			errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects %d arguments but got %d", name, parameters, len(args)))
+		}
+
+		in := make([]reflect.Value, len(args))
+		for index, arg := range args {
+			parameter := t.In(min(index, parameters-1))
+			if t.IsVariadic() && index >= parameters-1 {
+				parameter = parameter.Elem()
+			}
+
+			converted, err := fromValue(arg, parameter, nil)
+			if err != nil {
+				errors.RaiseRuntime(nil, fmt.Sprintf("%s() argument %d: %v", name, index+1, err))
+			}
+			in[index] = converted
+		}
+
+		out := fn.Call(in)
+
+		// A trailing error fails the call ---
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				// A limit hit by a script fn called stops the whole run, past any catch ---
+				var limit *errors.LimitError
+				if stderrors.As(err, &limit) {
+					panic(limit)
+				}
+				errors.RaiseRuntime(nil, fmt.Sprintf("%s() failed: %v", name, err))
+			}
+			out = out[:len(out)-1]
+		}
+
+		if len(out) == 0 {
+			return runtime.NIL()
+		}
+
+		result, err := toValue(out[0], nil)
+		if err != nil {
+			errors.RaiseRuntime(nil, fmt.Sprintf("%s() returned a value scripts cannot use: %v", name, err))
+		}
+		return result
+	})
+}
+
+// checkResults reports whether a Go function of type t returns something a
+// native function can: nothing, a value, an error, or a value and an error.
+func checkResults(t reflect.Type) error {
+	switch {
+	case t.NumOut() <= 1:
+		return nil
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+		return nil
+	}
+	return fmt.Errorf("a function must return at most a value and an error, %s returns %d values", t, t.NumOut())
+}
+
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func:
+		return value.IsNil()
+	}
+	return false
+}
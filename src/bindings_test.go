@@ -0,0 +1,242 @@
+package mutex
+
+import (
+	"bytes"
+	stderrors "errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/runtime"
+)
+
+// TestRegisterBuiltin checks that a host may replace a built-in function,
+// but not a global it defined itself.
+func TestRegisterBuiltin(t *testing.T) {
+	var stdout bytes.Buffer
+	interpreter := NewInterpreter(WithStdout(&stdout))
+
+	if err := interpreter.Register("upper", func(s string) string { return "<" + s + ">" }); err != nil {
+		t.Fatalf("replacing a built-in: %v", err)
+	}
+	if err := interpreter.Register("upper", strings.ToUpper); err == nil {
+		t.Error("registering the same name twice did not fail")
+	}
+
+	if _, err := interpreter.Eval(`echo(upper("a"));`); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "\"<a>\"\n" {
+		t.Errorf("printed %q", stdout.String())
+	}
+}
+
+// TestCallReentrant checks that a registered Go function can call back into
+// the script that called it, on both backends.
+func TestCallReentrant(t *testing.T) {
+	const script = `
+fn fib(n) {
+  if (n < 2) {
+    return n;
+  }
+  return host_fib(n - 1) + host_fib(n - 2);
+}
+
+fn fail() {
+  throw error("Oops", "from the script");
+}
+
+fn spin() {
+  while (true) {}
+}`
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			interpreter := NewInterpreter(WithBackend(backend), WithStdout(&stdout), WithStepLimit(100000))
+
+			register := func(name string, fn any) {
+				if err := interpreter.Register(name, fn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			register("host_fib", func(n int) (int, error) { return CallAs[int](interpreter, "fib", n) })
+			register("host_fail", func() error { _, err := interpreter.Call("fail"); return err })
+			register("host_spin", func() error { _, err := interpreter.Call("spin"); return err })
+
+			if _, err := interpreter.Eval(script); err != nil {
+				t.Fatal(err)
+			}
+
+			// Script to Go to script, many levels deep ---
+			if result, err := interpreter.Eval("fib(12);"); err != nil || result.String() != "144" {
+				t.Errorf("fib(12) from a script = %v, %v", result, err)
+			}
+			if result, err := CallAs[int](interpreter, "fib", 15); err != nil || result != 610 {
+				t.Errorf("fib(15) from Go = %v, %v", result, err)
+			}
+
+			// An error the nested call returns fails the Go function, which the script can catch ---
+			_, err := interpreter.Eval(`try { host_fail(); } catch (e) { echo(e.message); }`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stdout.String(), "from the script") {
+				t.Errorf("caught %q", stdout.String())
+			}
+
+			// A limit the nested call runs into stops the outer run too ---
+			stdout.Reset()
+			_, err = interpreter.Eval(`try { host_spin(); } catch (e) { echo("caught"); }`)
+			var limit *errors.LimitError
+			if !stderrors.As(err, &limit) {
+				t.Errorf("got error %v, want an *errors.LimitError", err)
+			}
+			if stdout.Len() > 0 {
+				t.Errorf("the script caught the limit: %q", stdout.String())
+			}
+
+			// Every nested run ended, so the next one starts with a fresh budget ---
+			if result, err := CallAs[int](interpreter, "fib", 10); err != nil || result != 55 {
+				t.Errorf("fib(10) after the limit = %v, %v", result, err)
+			}
+		})
+	}
+}
+
+// TestFromValueRange checks that numbers convert to Go integers only when
+// they fit, right up to the limits of each type.
+func TestFromValueRange(t *testing.T) {
+	tests := []struct {
+		number float64
+		as     func(runtime.RuntimeValue) (any, error)
+		want   any // nil when the number is out of range
+	}{
+		{127, convert[int8], int8(127)},
+		{-128, convert[int8], int8(-128)},
+		{128, convert[int8], nil},
+		{-129, convert[int8], nil},
+		{-(1 << 63), convert[int64], int64(math.MinInt64)},
+		{1 << 62, convert[int64], int64(1 << 62)},
+		{1 << 63, convert[int64], nil},
+		{1e19, convert[int64], nil},
+		{-1e19, convert[int64], nil},
+		{0, convert[uint], uint(0)},
+		{-1, convert[uint], nil},
+		{1 << 63, convert[uint], uint(1 << 63)},
+		{1e20, convert[uint], nil},
+		{1 << 63, convert[uint64], uint64(1 << 63)},
+		{1 << 64, convert[uint64], nil},
+		{1e20, convert[uint64], nil},
+		{1.5, convert[int64], nil},
+	}
+
+	for _, test := range tests {
+		got, err := test.as(runtime.NUMBER(test.number))
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%v converted to %T %v, want an error", test.number, got, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%v converted to %v, %v, want %v", test.number, got, err, test.want)
+		}
+	}
+}
+
+func convert[T any](value runtime.RuntimeValue) (any, error) {
+	return FromValue[T](value)
+}
+
+// TestConversion checks that values a script returns convert to Go, and
+// that one containing itself fails instead of converting forever.
+func TestConversion(t *testing.T) {
+	interpreter := NewInterpreter()
+	if _, err := interpreter.Eval(`
+fn number() { return 42; }
+fn text() { return "hi"; }
+fn array() { return [1, "two", [true, nil]]; }
+fn object() { return {a: 1, b: {c: [2]}}; }
+fn cyclic() { var mut a = [1]; push(a, a); return a; }
+fn cyclic_map() { var mut m = {}; m.self = [m]; return m; }`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := CallAs[int](interpreter, "number"); err != nil || got != 42 {
+		t.Errorf("int: %v, %v", got, err)
+	}
+	if got, err := CallAs[string](interpreter, "text"); err != nil || got != "hi" {
+		t.Errorf("string: %q, %v", got, err)
+	}
+
+	array, err := CallAs[[]any](interpreter, "array")
+	if want := []any{1.0, "two", []any{true, nil}}; err != nil || !reflect.DeepEqual(array, want) {
+		t.Errorf("[]any: %v, %v, want %v", array, err, want)
+	}
+
+	object, err := CallAs[map[string]any](interpreter, "object")
+	if want := map[string]any{"a": 1.0, "b": map[string]any{"c": []any{2.0}}}; err != nil || !reflect.DeepEqual(object, want) {
+		t.Errorf("map[string]any: %v, %v, want %v", object, err, want)
+	}
+
+	if _, err := CallAs[any](interpreter, "cyclic"); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("any of a cyclic array: %v", err)
+	}
+	if _, err := CallAs[[]any](interpreter, "cyclic"); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("[]any of a cyclic array: %v", err)
+	}
+	if _, err := CallAs[map[string][]any](interpreter, "cyclic_map"); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("map[string][]any of a cyclic map: %v", err)
+	}
+
+	// Go values containing themselves fail too ---
+	loop := []any{1}
+	loop[0] = loop
+	if _, err := ToValue(loop); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("ToValue of a cyclic slice: %v", err)
+	}
+	nested := map[string]any{}
+	nested["self"] = []any{nested}
+	if _, err := ToValue(nested); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("ToValue of a cyclic map: %v", err)
+	}
+
+	// The same value twice side by side is not a cycle ---
+	shared := []any{1.0}
+	if value, err := ToValue([]any{shared, shared}); err != nil || value.String() != "[[1], [1]]" {
+		t.Errorf("ToValue of a shared slice: %v, %v", value, err)
+	}
+}
+
+// TestEvalReentrant checks that a registered Go function can run more code
+// with Eval while the script that called it is still running.
+func TestEvalReentrant(t *testing.T) {
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			interpreter := NewInterpreter(WithBackend(backend))
+			err := interpreter.Register("host_eval", func(source string) (runtime.RuntimeValue, error) {
+				return interpreter.Eval(source)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := interpreter.Eval(`
+fn f(a) {
+  var imm x = host_eval("1 + 2;");
+  return a + x;
+}
+var imm total = f(10) + f(20);
+try {
+  host_eval("throw error('Oops', 'nested');");
+} catch (e) {}
+total;`)
+			if err != nil || result.String() != "36" {
+				t.Errorf("got %v, %v, want 36", result, err)
+			}
+		})
+	}
+}
@@ -3,19 +3,270 @@ package errors
 import (
 	"fmt"
 	"os"
+	"strings"
+
+	"github.com/caelondev/mutex/src/frontend/lexer"
 )
 
+// ParseError is produced when the parser meets a token it cannot make sense of.
+type ParseError struct {
+	Message string
+	Token   *lexer.Token
+	Span    lexer.Span
+	Line    int
+	Column  int
+}
 
-func ReportError(line int, message string) {
-	Report(line, "Report", message)
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("[%d:%d] Parser::Error -> %s", e.Line, e.Column, e.Message)
 }
 
-func ReportParser(message string, code int) {
-	fmt.Fprintf(os.Stderr, "\t|\n")
-	fmt.Fprintf(os.Stderr, "\t| Parser::Error -> %s\n", message)
-	fmt.Fprintf(os.Stderr, "\t|\n")
+// RuntimeError is produced while evaluating an AST. Token is the offending
+// operator token when one is known and nil otherwise; Span is the source
+// region of the innermost node being evaluated when the error was raised.
+type RuntimeError struct {
+	Message string
+	Token   *lexer.Token
+	Span    lexer.Span
+	Line    int
+	Column  int
+	File    string  // Path of the file Span is in, empty for source not read from a file
+	Value   any     // Value a throw statement threw, nil for errors raised by the interpreter
+	Trace   []Frame // Calls the error passed through, innermost first
 
-	os.Exit(code)
+	leaving bool // The error left the call of Trace's last frame, so the next location starts a new frame
+}
+
+// Frame is one call a runtime error passed through on its way out: where the
+// call had got to, and the function it was a call of.
+type Frame struct {
+	Function string // Empty for the top level of a file, or a call the error has not left
+	Native   bool   // Natives have no location
+	File     string
+	Span     lexer.Span
+	Line     int
+	Column   int
+}
+
+func (f Frame) String() string {
+	if f.Native {
+		return fmt.Sprintf("at %s (native)", f.Function)
+	}
+
+	file := f.File
+	if file == "" {
+		file = "<input>"
+	}
+
+	location := fmt.Sprintf("%s:%d:%d", file, f.Line, f.Column)
+	if f.Function == "" {
+		return "at " + location
+	}
+	return fmt.Sprintf("at %s (%s)", f.Function, location)
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("Interpreter::Error -> %s", e.Message)
+	}
+	return fmt.Sprintf("[%d:%d] Interpreter::Error -> %s", e.Line, e.Column, e.Message)
+}
+
+// ResolveError is produced when a program parses but misuses a variable,
+// such as reading it before its declaration or assigning to an immutable one.
+type ResolveError struct {
+	Message string
+	Span    lexer.Span
+	Line    int
+	Column  int
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("[%d:%d] Resolver::Error -> %s", e.Line, e.Column, e.Message)
+}
+
+// ModuleError is produced when an imported file fails to scan, parse or
+// resolve. Err holds the errors found in it.
+type ModuleError struct {
+	Path string
+	Err  error
+}
+
+func (e *ModuleError) Error() string {
+	return fmt.Sprintf("In module %s:\n%v", e.Path, e.Err)
+}
+
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+// LimitError is produced when a script runs past a limit the host set on it:
+// its step budget, its memory cap, or the cancellation of its context. Err is
+// the context's error when the context ended the script. Scripts cannot catch
+// it, so nothing they do keeps them running.
+type LimitError struct {
+	Reason string
+	Err    error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Execution limit exceeded: %s", e.Reason)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList collects several errors found in a single pass, such as every
+// malformed token the scanner ran into.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for i, err := range l {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	return l
+}
+
+func NewParseError(token *lexer.Token, message string) *ParseError {
+	err := &ParseError{Message: message, Token: token}
+	if token != nil {
+		err.Span = token.Span()
+		err.Line = token.Line
+		err.Column = token.Column
+	}
+	return err
+}
+
+func NewResolveError(span lexer.Span, message string) *ResolveError {
+	return &ResolveError{
+		Message: message,
+		Span:    span,
+		Line:    span.Start.Line,
+		Column:  span.Start.Column,
+	}
+}
+
+func NewRuntimeError(token *lexer.Token, message string) *RuntimeError {
+	err := &RuntimeError{Message: message, Token: token}
+	if token != nil {
+		err.Locate(token.Span())
+	}
+	return err
+}
+
+// Locate records span as the place the error happened.
+func (e *RuntimeError) Locate(span lexer.Span) {
+	e.Span = span
+	e.Line = span.Start.Line
+	e.Column = span.Start.Column
+}
+
+// LocateFrame records span, in file, as where the innermost call the error is
+// still inside had got to. Only the first location of each call counts, as
+// evaluation reports the innermost node first. The first frame is where the
+// error itself happened, so it needs Locate to have been called before.
+func (e *RuntimeError) LocateFrame(span lexer.Span, file string) {
+	if len(e.Trace) == 0 {
+		span, file = e.Span, e.File
+	} else if !e.leaving {
+		return
+	}
+
+	e.Trace = append(e.Trace, Frame{
+		File:   file,
+		Span:   span,
+		Line:   span.Start.Line,
+		Column: span.Start.Column,
+	})
+	e.leaving = false
+}
+
+// LeaveFunction records that the error left a call of the function name.
+func (e *RuntimeError) LeaveFunction(name string, native bool) {
+	// A call that never got located, like a native's, gets a frame of its own ---
+	if len(e.Trace) == 0 || e.leaving {
+		e.Trace = append(e.Trace, Frame{})
+	}
+
+	frame := &e.Trace[len(e.Trace)-1]
+	frame.Function = name
+	frame.Native = native
+	e.leaving = true
+}
+
+// Traceback lists the calls the error passed through, innermost first, one
+// per line. Runs of the same frame, as deep recursion leaves, are cut short.
+func (e *RuntimeError) Traceback() string {
+	const shown = 3
+
+	lines := []string{}
+	for i := 0; i < len(e.Trace); {
+		run := 1
+		for i+run < len(e.Trace) && e.Trace[i+run] == e.Trace[i] {
+			run++
+		}
+
+		for range min(run, shown) {
+			lines = append(lines, e.Trace[i].String())
+		}
+		if run > shown {
+			lines = append(lines, fmt.Sprintf("[previous frame repeated %d more times]", run-shown))
+		}
+		i += run
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RaiseParser aborts parsing with a ParseError. The error unwinds the parser
+// and is handed back to the caller of parser.ProduceAST.
+func RaiseParser(token *lexer.Token, message string) {
+	panic(NewParseError(token, message))
+}
+
+// RaiseRuntime aborts evaluation with a RuntimeError. The error unwinds the
+// evaluator and is handed back to the caller of runtime.EvaluateStatement or
+// runtime.EvaluateExpression.
+func RaiseRuntime(token *lexer.Token, message string) {
+	panic(NewRuntimeError(token, message))
+}
+
+// RaiseLimit aborts evaluation with a LimitError, which unwinds the
+// evaluator like a RuntimeError but passes every catch and finally clause.
+func RaiseLimit(reason string, err error) {
+	panic(&LimitError{Reason: reason, Err: err})
+}
+
+// Recover turns a raised ParseError, RuntimeError, ModuleError or LimitError
+// back into an ordinary error. It must be deferred directly; any other panic
+// is re-raised.
+func Recover(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	switch e := r.(type) {
+	case *ParseError:
+		*err = e
+	case *RuntimeError:
+		*err = e
+	case *ModuleError:
+		*err = e
+	case *LimitError:
+		*err = e
+	default:
+		panic(r)
+	}
+}
+
+func ReportError(line int, message string) {
+	Report(line, "Report", message)
 }
 
 func Report(line int, where, message string) {
@@ -24,15 +275,48 @@ func Report(line int, where, message string) {
 	fmt.Fprintf(os.Stderr, "     |\n")
 }
 
-func Exit(code int) {
-	fmt.Printf("\n[Process exited with code: %d]\n", code)
-	os.Exit(code)
+// ReportMessage prints an error like Report for an error that belongs to no
+// line of the source.
+func ReportMessage(where, message string) {
+	fmt.Fprintf(os.Stderr, "     |\n")
+	fmt.Fprintf(os.Stderr, "     | %s::Error -> %s\n", where, message)
+	fmt.Fprintf(os.Stderr, "     |\n")
 }
 
-func ReportInterpreter(message string, code int) {
-	fmt.Fprintf(os.Stderr, "\t|\n")
-	fmt.Fprintf(os.Stderr, "\t| Interpreter::Error -> %s\n", message)
-	fmt.Fprintf(os.Stderr, "\t|\n")
+// ReportSource prints an error like Report, followed by the offending source
+// line with the span underlined.
+func ReportSource(sourceCode string, span lexer.Span, where, message string) {
+	lines := strings.Split(sourceCode, "\n")
+	if span.IsZero() || span.Start.Line > len(lines) {
+		Report(span.Start.Line, where, message)
+		return
+	}
 
+	line := []rune(strings.TrimRight(lines[span.Start.Line-1], "\r"))
+	start := min(span.Start.Column-1, len(line))
+
+	width := len(line) - start
+	if span.End.Line == span.Start.Line {
+		width = min(span.End.Column-span.Start.Column, width)
+	}
+	width = max(width, 1)
+
+	// Keep tabs in the padding so the carets line up with the source ---
+	padding := []rune(strings.Repeat(" ", start))
+	for i := range start {
+		if line[i] == '\t' {
+			padding[i] = '\t'
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "     |\n")
+	fmt.Fprintf(os.Stderr, "%4d | %s::Error -> %s\n", span.Start.Line, where, message)
+	fmt.Fprintf(os.Stderr, "     |\n")
+	fmt.Fprintf(os.Stderr, "%4d | %s\n", span.Start.Line, string(line))
+	fmt.Fprintf(os.Stderr, "     | %s%s\n", string(padding), strings.Repeat("^", width))
+}
+
+func Exit(code int) {
+	fmt.Printf("\n[Process exited with code: %d]\n", code)
 	os.Exit(code)
 }
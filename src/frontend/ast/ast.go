@@ -1,9 +1,38 @@
 package ast
 
+import "github.com/caelondev/mutex/src/frontend/lexer"
+
 type Statement interface {
 	Statement()
+	Span() lexer.Span
+	SetSpan(span lexer.Span)
 }
 
 type Expression interface {
 	Expression()
+	Span() lexer.Span
+	SetSpan(span lexer.Span)
+}
+
+// Node is embedded in every statement and expression and records the region
+// of source code the node was parsed from.
+type Node struct {
+	Location lexer.Span
+}
+
+func (n *Node) Span() lexer.Span {
+	return n.Location
+}
+
+func (n *Node) SetSpan(span lexer.Span) {
+	n.Location = span
+}
+
+// Binding records where the variable a node refers to or declares lives, as
+// worked out by the resolver. Local variables sit in slot Slot of the scope
+// Depth scopes out from the node; globals are looked up by name.
+type Binding struct {
+	Local bool
+	Depth int
+	Slot  int
 }
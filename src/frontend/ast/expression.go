@@ -3,24 +3,29 @@ package ast
 import "github.com/caelondev/mutex/src/frontend/lexer"
 
 type NumberExpression struct {
+	Node
 	Value float64
 }
 
 func (node *NumberExpression) Expression() {}
 
 type StringExpression struct {
+	Node
 	Value string
 }
 
 func (node *StringExpression) Expression() {}
 
 type SymbolExpression struct {
-	Value string
+	Node
+	Value   string
+	Binding Binding
 }
 
 func (node *SymbolExpression) Expression() {}
 
 type BinaryExpression struct {
+	Node
 	Left     Expression
 	Right    Expression
 	Operator lexer.Token
@@ -29,6 +34,7 @@ type BinaryExpression struct {
 func (node *BinaryExpression) Expression() {}
 
 type AssignmentExpression struct {
+	Node
 	Assignee Expression
 	NewValue Expression
 }
@@ -36,6 +42,7 @@ type AssignmentExpression struct {
 func (node *AssignmentExpression) Expression() {}
 
 type UnaryExpression struct {
+	Node
 	Operator lexer.Token
 	Operand  Expression
 }
@@ -43,6 +50,7 @@ type UnaryExpression struct {
 func (node *UnaryExpression) Expression() {}
 
 type PostfixExpression struct {
+	Node
 	Operator lexer.Token
 	Operand  Expression
 }
@@ -50,12 +58,14 @@ type PostfixExpression struct {
 func (node *PostfixExpression) Expression() {}
 
 type ArrayExpression struct {
+	Node
 	Elements []Expression
 }
 
 func (node *ArrayExpression) Expression() {}
 
 type ArrayIndexExpression struct {
+	Node
 	Object Expression
 	Index  Expression
 }
@@ -63,6 +73,7 @@ type ArrayIndexExpression struct {
 func (node *ArrayIndexExpression) Expression() {}
 
 type ArrayIndexAssignmentExpression struct {
+	Node
 	Object   Expression
 	Index    Expression
 	NewValue Expression
@@ -71,8 +82,62 @@ type ArrayIndexAssignmentExpression struct {
 func (node *ArrayIndexAssignmentExpression) Expression() {}
 
 type CallExpression struct {
+	Node
 	Callee    Expression
 	Arguments []Expression
 }
 
 func (c *CallExpression) Expression() {}
+
+type MapEntry struct {
+	Key   string
+	Value Expression
+}
+
+type MapExpression struct {
+	Node
+	Entries []MapEntry
+}
+
+func (node *MapExpression) Expression() {}
+
+type MemberExpression struct {
+	Node
+	Object   Expression
+	Property string
+}
+
+func (node *MemberExpression) Expression() {}
+
+type MemberAssignmentExpression struct {
+	Node
+	Object   Expression
+	Property string
+	NewValue Expression
+}
+
+func (node *MemberAssignmentExpression) Expression() {}
+
+type ThisExpression struct {
+	Node
+	Binding Binding
+}
+
+func (node *ThisExpression) Expression() {}
+
+type SuperExpression struct {
+	Node
+	Method string
+	Super  Binding
+	This   Binding
+}
+
+func (node *SuperExpression) Expression() {}
+
+type FunctionExpression struct {
+	Node
+	Parameters []string
+	Body       Statement
+}
+
+func (node *FunctionExpression) Expression() {}
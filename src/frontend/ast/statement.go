@@ -1,26 +1,32 @@
 package ast
 
 type BlockStatement struct {
-	Body []Statement
+	Node
+	Body  []Statement
+	Slots int // Number of variables declared directly in the block, set by the resolver
 }
 
 func (node *BlockStatement) Statement() {}
 
 type ExpressionStatement struct {
+	Node
 	Expression Expression
 }
 
 func (node *ExpressionStatement) Statement() {}
 
 type VariableDeclarationStatement struct {
+	Node
 	IsMutable  bool
 	Identifier string
 	Value      Expression
+	Binding    Binding
 }
 
 func (node *VariableDeclarationStatement) Statement() {}
 
 type IfStatement struct {
+	Node
 	Condition  Expression
 	Consequent Statement
 	Alternate  Statement
@@ -29,6 +35,8 @@ type IfStatement struct {
 func (node *IfStatement) Statement() {}
 
 type WhileStatement struct {
+	Node
+	Label     string // Empty for unlabeled loops
 	Condition Expression
 	Body      Statement
 }
@@ -36,6 +44,8 @@ type WhileStatement struct {
 func (node *WhileStatement) Statement() {}
 
 type ForStatement struct {
+	Node
+	Label       string // Empty for unlabeled loops
 	Initializer Statement
 	Condition   Expression
 	Increment   Expression
@@ -44,16 +54,89 @@ type ForStatement struct {
 
 func (node *ForStatement) Statement() {}
 
+type ForInStatement struct {
+	Node
+	Label     string // Empty for unlabeled loops
+	IsMutable bool
+	Variable  string
+	Iterable  Expression
+	Body      Statement
+}
+
+func (node *ForInStatement) Statement() {}
+
 type FunctionDeclaration struct {
+	Node
 	Name       string
 	Parameters []string
 	Body       Statement
+	Binding    Binding
 }
 
 func (f *FunctionDeclaration) Statement() {}
 
 type ReturnStatement struct {
+	Node
 	Value Expression // Can be nil for bare "return;"
 }
 
 func (r *ReturnStatement) Statement() {}
+
+type BreakStatement struct {
+	Node
+	Label string // Can be empty for bare "break;"
+}
+
+func (b *BreakStatement) Statement() {}
+
+type ContinueStatement struct {
+	Node
+	Label string // Can be empty for bare "continue;"
+}
+
+func (c *ContinueStatement) Statement() {}
+
+type ClassDeclaration struct {
+	Node
+	Name       string
+	Superclass string // Empty when the class does not inherit
+	Methods    []*FunctionDeclaration
+	Binding    Binding
+
+	SuperclassBinding Binding // Where Superclass lives
+}
+
+func (c *ClassDeclaration) Statement() {}
+
+type ImportStatement struct {
+	Node
+	Path    string // As written, resolved against the importing file
+	Name    string
+	Binding Binding
+}
+
+func (i *ImportStatement) Statement() {}
+
+type ExportStatement struct {
+	Node
+	Declaration Statement // A variable, function or class declaration
+}
+
+func (e *ExportStatement) Statement() {}
+
+type ThrowStatement struct {
+	Node
+	Value Expression
+}
+
+func (t *ThrowStatement) Statement() {}
+
+type TryStatement struct {
+	Node
+	Body      *BlockStatement
+	CatchName string          // Variable the caught value is bound to
+	Catch     *BlockStatement // nil without a catch clause
+	Finally   *BlockStatement // nil without a finally clause
+}
+
+func (t *TryStatement) Statement() {}
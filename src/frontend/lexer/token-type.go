@@ -40,6 +40,8 @@ const (
 	MINUS_MINUS
 	PLUS_PLUS
 
+	ARROW
+
 	// Literals ---
 	IDENTIFIER
 	STRING
@@ -60,6 +62,16 @@ const (
 	THIS
 	VAR
 	WHILE
+	BREAK
+	CONTINUE
+	IN
+	IMPORT
+	EXPORT
+	AS
+	TRY
+	CATCH
+	FINALLY
+	THROW
 
 	EOF
 )
@@ -80,6 +92,16 @@ var RESERVED_KEYWORDS map[string]TokenType = map[string]TokenType{
 	"var": VAR,
 	"while": WHILE,
 	"for": FOR,
+	"break": BREAK,
+	"continue": CONTINUE,
+	"in": IN,
+	"import": IMPORT,
+	"export": EXPORT,
+	"as": AS,
+	"try": TRY,
+	"catch": CATCH,
+	"finally": FINALLY,
+	"throw": THROW,
 }
 
 func TokenTypeString(t TokenType) string {
@@ -92,6 +114,10 @@ func TokenTypeString(t TokenType) string {
 		return "LEFT_BRACE"
 	case RIGHT_BRACE:
 		return "RIGHT_BRACE"
+	case LEFT_BRACKET:
+		return "LEFT_BRACKET"
+	case RIGHT_BRACKET:
+		return "RIGHT_BRACKET"
 	case COMMA:
 		return "COMMA"
 	case DOT:
@@ -100,12 +126,16 @@ func TokenTypeString(t TokenType) string {
 		return "MINUS"
 	case PLUS:
 		return "PLUS"
+	case COLON:
+		return "COLON"
 	case SEMICOLON:
 		return "SEMICOLON"
 	case SLASH:
 		return "SLASH"
 	case STAR:
 		return "STAR"
+	case MODULO:
+		return "MODULO"
 
 	// one/two char
 	case NOT:
@@ -162,6 +192,26 @@ func TokenTypeString(t TokenType) string {
 		return "WHILE"
 	case FOR:
 		return "FOR"
+	case BREAK:
+		return "BREAK"
+	case CONTINUE:
+		return "CONTINUE"
+	case IN:
+		return "IN"
+	case IMPORT:
+		return "IMPORT"
+	case EXPORT:
+		return "EXPORT"
+	case AS:
+		return "AS"
+	case TRY:
+		return "TRY"
+	case CATCH:
+		return "CATCH"
+	case FINALLY:
+		return "FINALLY"
+	case THROW:
+		return "THROW"
 	case PLUS_EQUALS:
 		return "PLUS_EQUALS"
 	case MINUS_EQUALS:
@@ -176,6 +226,8 @@ func TokenTypeString(t TokenType) string {
 		return "PLUS_PLUS"
 	case MINUS_MINUS:
 		return "MINUS_MINUS"
+	case ARROW:
+		return "ARROW"
 
 	case EOF:
 		return "EOF"
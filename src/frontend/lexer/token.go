@@ -2,19 +2,48 @@ package lexer
 
 import "fmt"
 
+// Position is a location in source code. Line and Column are 1-based and
+// count runes, Offset is the 0-based byte offset from the start of the source.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// Span covers the source from Start up to, but not including, End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) IsZero() bool {
+	return s.Start.Line == 0
+}
+
 type Token struct {
 	TokenType TokenType
 	Lexeme    string
 	Literal   any
 	Line      int
+	Column    int
+	Offset    int
+	End       Position // Position right after the last rune of the token
 }
 
-func NewToken(tokenType TokenType, lexeme string, literal any, line int) Token {
+func NewToken(tokenType TokenType, lexeme string, literal any, span Span) Token {
 	return Token{
-		tokenType, lexeme, literal, line,
+		tokenType, lexeme, literal, span.Start.Line, span.Start.Column, span.Start.Offset, span.End,
 	}
 }
 
+func (t *Token) Position() Position {
+	return Position{Line: t.Line, Column: t.Column, Offset: t.Offset}
+}
+
+func (t *Token) Span() Span {
+	return Span{Start: t.Position(), End: t.End}
+}
+
 func (t *Token) String() string {
 	return fmt.Sprintf("Token Type: %s\nValue: %s\nLiteral: %v\n", TokenTypeString(t.TokenType), t.Lexeme, t.Literal)
 }
@@ -15,24 +15,28 @@ func parseExpression(p *parser, bp BindingPower) ast.Expression {
 	nudFunction, exists := nudLU[tokenType]
 
 	if p.isEOF() {
-		errors.ReportParser("Unexpected end of file expression (EOF)", 0)
+		errors.RaiseParser(p.currentToken(), "Unexpected end of file expression (EOF)")
 	}
 
 	if !exists {
-		errors.ReportParser(fmt.Sprintf("Unrecognized token found in the begining of an expression: %s", lexer.TokenTypeString(tokenType)), 0)
+		errors.RaiseParser(p.currentToken(), fmt.Sprintf("Unrecognized token found in the begining of an expression: %s", lexer.TokenTypeString(tokenType)))
 	}
 
+	startToken := p.currentToken()
 	left := nudFunction(p)
+	left.SetSpan(p.spanFrom(startToken.Position()))
 
 	for !p.isEOF() && bindingPowerLU[p.currentTokenType()] > bp {
 		tokenType = p.currentTokenType()
 		ledFunction, exists := ledLU[tokenType]
 
 		if !exists {
-			errors.ReportParser(fmt.Sprintf("Unrecognized token found in the middle of an expression: %s (LED)", lexer.TokenTypeString(tokenType)), 0)
+			errors.RaiseParser(p.currentToken(), fmt.Sprintf("Unrecognized token found in the middle of an expression: %s (LED)", lexer.TokenTypeString(tokenType)))
 		}
 
+		start := left.Span().Start
 		left = ledFunction(p, left, bindingPowerLU[p.currentTokenType()])
+		left.SetSpan(p.spanFrom(start))
 	}
 
 	return left
@@ -54,13 +58,18 @@ func parsePrimaryExpression(p *parser) ast.Expression {
 			Value: p.advance().Lexeme,
 		}
 	case lexer.LEFT_PARENTHESIS:
+		if p.isArrowFunction() {
+			return parseArrowFunction(p)
+		}
+
 		p.advance() // eat ( ---
 		value := parseExpression(p, DEFAULT_BP)
 		p.expect(lexer.RIGHT_PARENTHESIS)
 		return value
 
 	default:
-		panic(fmt.Sprintf("Unrecognized primary token found: '%s'", lexer.TokenTypeString(p.currentTokenType())))
+		errors.RaiseParser(p.currentToken(), fmt.Sprintf("Unrecognized primary token found: '%s'", lexer.TokenTypeString(p.currentTokenType())))
+		return nil
 	}
 }
 
@@ -79,39 +88,13 @@ func parseVariableAssignmentExpression(p *parser, left ast.Expression, bp Bindin
 	operatorToken := p.advance() // Get the operator (=, +=, -=, etc.)
 	value := parseExpression(p, ASSIGNMENT)
 
+	// Handle compound assignment: x += 5  becomes  x = x + 5
+	if operatorToken.TokenType != lexer.ASSIGNMENT {
+		value = parseCompoundAssignmentValue(p, operatorToken, left, value)
+	}
+
 	// Check if left side is an index expression (arr[0] = ...)
 	if indexExpr, ok := left.(*ast.ArrayIndexExpression); ok {
-		// Handle compound assignment for arrays
-		if operatorToken.TokenType != lexer.ASSIGNMENT {
-			var binaryOp lexer.TokenType
-			switch operatorToken.TokenType {
-			case lexer.PLUS_EQUALS:
-				binaryOp = lexer.PLUS
-			case lexer.MINUS_EQUALS:
-				binaryOp = lexer.MINUS
-			case lexer.STAR_EQUALS:
-				binaryOp = lexer.STAR
-			case lexer.SLASH_EQUALS:
-				binaryOp = lexer.SLASH
-			case lexer.MODULO_EQUALS:
-				binaryOp = lexer.MODULO
-			default:
-				errors.ReportParser(fmt.Sprintf("Unrecognized compound assignment operator: %s", lexer.TokenTypeString(operatorToken.TokenType)), 65)
-			}
-
-			// arr[i] += 5  becomes  arr[i] = arr[i] + 5
-			value = &ast.BinaryExpression{
-				Left:  indexExpr, // arr[i]
-				Right: value,
-				Operator: lexer.Token{
-					TokenType: binaryOp,
-					Lexeme:    lexer.TokenTypeString(binaryOp),
-					Literal:   nil,
-					Line:      operatorToken.Line,
-				},
-			}
-		}
-
 		return &ast.ArrayIndexAssignmentExpression{
 			Object:   indexExpr.Object,
 			Index:    indexExpr.Index,
@@ -119,42 +102,57 @@ func parseVariableAssignmentExpression(p *parser, left ast.Expression, bp Bindin
 		}
 	}
 
-	// Handle regular variable assignment
-	if operatorToken.TokenType != lexer.ASSIGNMENT {
-		var binaryOp lexer.TokenType
-		switch operatorToken.TokenType {
-		case lexer.PLUS_EQUALS:
-			binaryOp = lexer.PLUS
-		case lexer.MINUS_EQUALS:
-			binaryOp = lexer.MINUS
-		case lexer.STAR_EQUALS:
-			binaryOp = lexer.STAR
-		case lexer.SLASH_EQUALS:
-			binaryOp = lexer.SLASH
-		case lexer.MODULO_EQUALS:
-			binaryOp = lexer.MODULO
-		default:
-			errors.ReportParser(fmt.Sprintf("Unrecognized compound assignment operator: %s", lexer.TokenTypeString(operatorToken.TokenType)), 65)
-		}
-
-		value = &ast.BinaryExpression{
-			Left:  left,
-			Right: value,
-			Operator: lexer.Token{
-				TokenType: binaryOp,
-				Lexeme:    lexer.TokenTypeString(binaryOp),
-				Literal:   nil,
-				Line:      operatorToken.Line,
-			},
+	// Check if left side is a member expression (map.key = ...)
+	if memberExpr, ok := left.(*ast.MemberExpression); ok {
+		return &ast.MemberAssignmentExpression{
+			Object:   memberExpr.Object,
+			Property: memberExpr.Property,
+			NewValue: value,
 		}
 	}
 
+	// Handle regular variable assignment
 	return &ast.AssignmentExpression{
 		Assignee: left,
 		NewValue: value,
 	}
 }
 
+func parseCompoundAssignmentValue(p *parser, operatorToken *lexer.Token, left ast.Expression, value ast.Expression) ast.Expression {
+	var binaryOp lexer.TokenType
+	switch operatorToken.TokenType {
+	case lexer.PLUS_EQUALS:
+		binaryOp = lexer.PLUS
+	case lexer.MINUS_EQUALS:
+		binaryOp = lexer.MINUS
+	case lexer.STAR_EQUALS:
+		binaryOp = lexer.STAR
+	case lexer.SLASH_EQUALS:
+		binaryOp = lexer.SLASH
+	case lexer.MODULO_EQUALS:
+		binaryOp = lexer.MODULO
+	default:
+		errors.RaiseParser(operatorToken, fmt.Sprintf("Unrecognized compound assignment operator: %s", lexer.TokenTypeString(operatorToken.TokenType)))
+	}
+
+	binary := &ast.BinaryExpression{
+		Left:  left,
+		Right: value,
+		Operator: lexer.Token{
+			TokenType: binaryOp,
+			Lexeme:    lexer.TokenTypeString(binaryOp),
+			Literal:   nil,
+			Line:      operatorToken.Line,
+			Column:    operatorToken.Column,
+			Offset:    operatorToken.Offset,
+			End:       operatorToken.End,
+		},
+	}
+	binary.SetSpan(p.spanFrom(left.Span().Start))
+
+	return binary
+}
+
 func parseUnaryExpression(p *parser) ast.Expression {
 	operatorToken := p.advance()
 	operand := parseExpression(p, UNARY)
@@ -216,3 +214,149 @@ func parseIndexExpression(p *parser, left ast.Expression, bp BindingPower) ast.E
 		Index:  index,
 	}
 }
+
+func parseMapExpression(p *parser) ast.Expression {
+	// SYNTAX ---
+	//
+	// { "key": value, identifier: value }
+	// { "key": value, }  // trailing comma allowed
+	//
+
+	p.advance() // eat '{'
+
+	var entries []ast.MapEntry
+
+	for p.currentTokenType() != lexer.RIGHT_BRACE {
+		key := p.expectError("Expected a string or identifier as map key", lexer.STRING, lexer.IDENTIFIER).Lexeme
+		p.expect(lexer.COLON)
+
+		entries = append(entries, ast.MapEntry{
+			Key:   key,
+			Value: parseExpression(p, DEFAULT_BP),
+		})
+
+		if p.currentTokenType() != lexer.COMMA {
+			break
+		}
+		p.advance() // eat ','
+	}
+
+	p.expect(lexer.RIGHT_BRACE) // eat '}'
+
+	return &ast.MapExpression{
+		Entries: entries,
+	}
+}
+
+func parseMemberExpression(p *parser, left ast.Expression, bp BindingPower) ast.Expression {
+	// Parse: object.property
+	p.advance() // eat '.'
+
+	property := p.expectError("Expected a property name after '.'", lexer.IDENTIFIER)
+
+	return &ast.MemberExpression{
+		Object:   left,
+		Property: property.Lexeme,
+	}
+}
+
+func parseThisExpression(p *parser) ast.Expression {
+	token := p.advance() // eat 'this'
+
+	if len(p.classes) == 0 {
+		errors.RaiseParser(token, "Cannot use 'this' outside of a class")
+	}
+
+	return &ast.ThisExpression{}
+}
+
+func parseSuperExpression(p *parser) ast.Expression {
+	// Parse: super.method
+	token := p.advance() // eat 'super'
+
+	if len(p.classes) == 0 {
+		errors.RaiseParser(token, "Cannot use 'super' outside of a class")
+	}
+	if !p.classes[len(p.classes)-1] {
+		errors.RaiseParser(token, "Cannot use 'super' in a class with no superclass")
+	}
+
+	p.expectError("Expected '.' after 'super'", lexer.DOT)
+	method := p.expectError("Expected a superclass method name after 'super.'", lexer.IDENTIFIER)
+
+	return &ast.SuperExpression{
+		Method: method.Lexeme,
+	}
+}
+
+func parseFunctionExpression(p *parser) ast.Expression {
+	// SYNTAX ---
+	//
+	// fn (param1, param2, ...) { ... }
+	//
+
+	p.advance() // Eat 'fn' ---
+
+	parameters := parseParameters(p)
+
+	p.expect(lexer.LEFT_BRACE)
+	body := parseFunctionBody(p)
+
+	return &ast.FunctionExpression{
+		Parameters: parameters,
+		Body:       body,
+	}
+}
+
+func parseArrowFunction(p *parser) ast.Expression {
+	// SYNTAX ---
+	//
+	// (param1, param2, ...) => expression
+	// (param1, param2, ...) => { ... }
+	//
+
+	parameters := parseParameters(p)
+	p.expect(lexer.ARROW)
+
+	if p.currentTokenType() == lexer.LEFT_BRACE {
+		p.advance() // eat '{'
+		return &ast.FunctionExpression{
+			Parameters: parameters,
+			Body:       parseFunctionBody(p),
+		}
+	}
+
+	// An expression body is returned as if written `{ return expression; }` ---
+	value := parseExpression(p, DEFAULT_BP)
+
+	returnStatement := &ast.ReturnStatement{Value: value}
+	returnStatement.SetSpan(value.Span())
+
+	body := &ast.BlockStatement{Body: []ast.Statement{returnStatement}}
+	body.SetSpan(value.Span())
+
+	return &ast.FunctionExpression{
+		Parameters: parameters,
+		Body:       body,
+	}
+}
+
+// isArrowFunction reports whether the '(' at the current position opens the
+// parameter list of an arrow function rather than a grouped expression.
+func (p *parser) isArrowFunction() bool {
+	depth := 0
+	for i := p.position; i < len(p.tokens); i++ {
+		switch p.tokens[i].TokenType {
+		case lexer.LEFT_PARENTHESIS:
+			depth++
+		case lexer.RIGHT_PARENTHESIS:
+			depth--
+			if depth == 0 {
+				return i+1 < len(p.tokens) && p.tokens[i+1].TokenType == lexer.ARROW
+			}
+		case lexer.EOF:
+			return false
+		}
+	}
+	return false
+}
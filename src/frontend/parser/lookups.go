@@ -63,6 +63,19 @@ func createTokenLookups() {
 	nud(lexer.LEFT_BRACKET, parseArrayExpression)
 	led(lexer.LEFT_BRACKET, CALL, parseIndexExpression)
 
+	// MAPS & MEMBER ACCESS ---
+	// NOTE: '{' also starts a block statement, which resets its binding power to DEFAULT_BP ---
+	nud(lexer.LEFT_BRACE, parseMapExpression)
+	led(lexer.DOT, MEMBER, parseMemberExpression)
+
+	// FUNCTION EXPRESSIONS ---
+	// NOTE: 'fn' also starts a function declaration, which resets its binding power to DEFAULT_BP ---
+	nud(lexer.FUNCTION, parseFunctionExpression)
+
+	// CLASSES ---
+	nud(lexer.THIS, parseThisExpression)
+	nud(lexer.SUPER, parseSuperExpression)
+
 	// PREFIX
 	nud(lexer.NOT, parseUnaryExpression)
 	nud(lexer.MINUS, parseUnaryExpression)
@@ -102,10 +115,18 @@ func createTokenLookups() {
 	led(lexer.LEFT_PARENTHESIS, CALL, parseCallExpression)
 
 	// Statements ---
+	statement(lexer.LEFT_BRACE, parseBlockStatement)
 	statement(lexer.VAR, parseVariableDeclaration)
 	statement(lexer.IF, parseIfStatement)
 	statement(lexer.WHILE, parseWhileStatement)
 	statement(lexer.FOR, parseForStatement)
 	statement(lexer.FUNCTION, parseFunctionDeclaration)
+	statement(lexer.CLASS, parseClassDeclaration)
 	statement(lexer.RETURN, parseReturnStatement)
+	statement(lexer.BREAK, parseBreakStatement)
+	statement(lexer.CONTINUE, parseContinueStatement)
+	statement(lexer.IMPORT, parseImportStatement)
+	statement(lexer.EXPORT, parseExportStatement)
+	statement(lexer.THROW, parseThrowStatement)
+	statement(lexer.TRY, parseTryStatement)
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/caelondev/mutex/src/errors"
 	"github.com/caelondev/mutex/src/frontend/ast"
@@ -12,23 +13,52 @@ import (
 type parser struct {
 	tokens   []*lexer.Token
 	position int
+	errs     errors.ErrorList
+
+	loops []string // Labels of the loops enclosing the current statement ---
+	label string   // Label waiting to be attached to the next loop ---
+
+	classes []bool // One entry per enclosing class, true if it has a superclass ---
+
+	nesting int // Number of statements enclosing the current one, plus one ---
 }
 
-func ProduceAST(tokens []*lexer.Token) ast.BlockStatement {
+// ProduceAST parses tokens into a program. The parser recovers from syntax
+// errors at statement boundaries, so it returns whatever it could parse along
+// with an errors.ErrorList holding every *errors.ParseError it ran into.
+func ProduceAST(tokens []*lexer.Token) (program ast.BlockStatement, err error) {
+	defer errors.Recover(&err)
+
 	body := make([]ast.Statement, 0)
 	p := instantiateParser(tokens)
 
 	for !p.isEOF() {
-		body = append(body, parseStatement(p))
+		if statement := parseStatement(p); statement != nil {
+			body = append(body, statement)
+		}
 	}
 
-	return ast.BlockStatement{
+	program = ast.BlockStatement{
 		Body: body,
 	}
+	program.SetSpan(lexer.Span{
+		Start: tokens[0].Position(),
+		End:   tokens[len(tokens)-1].Position(),
+	})
+
+	if len(p.errs) > 0 {
+		return program, p.errs
+	}
+
+	return program, nil
 }
 
+// The lookup tables are shared by every parser, so they are filled in once and
+// only read afterwards. This keeps concurrent ProduceAST calls race-free.
+var lookupsOnce sync.Once
+
 func instantiateParser(tokens []*lexer.Token) *parser {
-	createTokenLookups()
+	lookupsOnce.Do(createTokenLookups)
 
 	return &parser{
 		tokens:   tokens,
@@ -44,6 +74,12 @@ func (p *parser) previousTokenType() lexer.TokenType {
 	return p.previousToken().TokenType
 }
 
+// spanFrom returns the span running from start to the end of the last
+// consumed token.
+func (p *parser) spanFrom(start lexer.Position) lexer.Span {
+	return lexer.Span{Start: start, End: p.previousToken().End}
+}
+
 func (p *parser) currentToken() *lexer.Token {
 	return p.tokens[p.position]
 }
@@ -64,6 +100,15 @@ func (p *parser) ignore(tokenType lexer.TokenType) {
 	}
 }
 
+// peekTokenType returns the type of the token offset positions ahead of the
+// current one without consuming anything.
+func (p *parser) peekTokenType(offset int) lexer.TokenType {
+	if p.position+offset >= len(p.tokens) {
+		return lexer.EOF
+	}
+	return p.tokens[p.position+offset].TokenType
+}
+
 func (p *parser) isEOF() bool {
 	return p.position >= len(p.tokens) || p.currentTokenType() == lexer.EOF
 }
@@ -92,8 +137,61 @@ func (p *parser) expectError(err string, expectedTypes ...lexer.TokenType) *lexe
 			}
 			err = fmt.Sprintf("Expected %s but got %s instead", strings.Join(names, "/"), lexer.TokenTypeString(tokenType))
 		}
-		errors.ReportParser(err, 65)
+		errors.RaiseParser(token, err)
 	}
 
 	return p.advance()
 }
+
+// synchronize skips the rest of a statement that failed to parse, starting
+// at token index start, so parsing can resume at the next statement. Braces
+// opened by the broken statement are skipped as a whole.
+func (p *parser) synchronize(start int) {
+	if p.position == start && !p.isEOF() {
+		p.advance() // Always make progress past the offending token ---
+	}
+
+	depth := 0
+	for _, token := range p.tokens[start:p.position] {
+		switch token.TokenType {
+		case lexer.LEFT_BRACE:
+			depth++
+		case lexer.RIGHT_BRACE:
+			depth--
+		}
+	}
+	depth = max(depth, 0)
+
+	for !p.isEOF() {
+		switch p.currentTokenType() {
+		case lexer.SEMICOLON:
+			if depth == 0 {
+				p.advance()
+				return
+			}
+		case lexer.LEFT_BRACE:
+			depth++
+		case lexer.RIGHT_BRACE:
+			if depth == 0 {
+				return // Closes an enclosing block, leave it to parseBlock ---
+			}
+
+			depth--
+			if depth == 0 {
+				p.advance()
+
+				// The group may have been an expression, like a map or function literal ---
+				if p.currentTokenType() == lexer.SEMICOLON {
+					p.advance()
+				}
+				return
+			}
+		default:
+			if _, isStatement := statementLU[p.currentTokenType()]; isStatement && depth == 0 {
+				return
+			}
+		}
+
+		p.advance()
+	}
+}
@@ -0,0 +1,83 @@
+package parser_test
+
+import (
+	stderrors "errors"
+	"testing"
+
+	mutex "github.com/caelondev/mutex/src"
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/frontend/ast"
+	"github.com/caelondev/mutex/src/frontend/parser"
+)
+
+func parseErrors(t *testing.T, source string) []error {
+	t.Helper()
+
+	scanner := mutex.NewScanner(source)
+	tokens := scanner.ScanTokens()
+	if len(scanner.Errors) > 0 {
+		t.Fatalf("scanning %q: %v", source, scanner.Errors)
+	}
+
+	_, err := parser.ProduceAST(tokens)
+	if err == nil {
+		return nil
+	}
+
+	var list errors.ErrorList
+	if stderrors.As(err, &list) {
+		return list
+	}
+	return []error{err}
+}
+
+// A statement that fails inside a brace group is skipped up to and including
+// the semicolon ending it, so it reports one error rather than a second one
+// for the leftover semicolon.
+func TestSynchronizeAfterBraceGroup(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		errors int
+	}{
+		{"map literal", `var imm m = {1: 2};`, 1},
+		{"named function expression", `var imm f = fn named() { return 1; };`, 1},
+		{"statement after the error", `var imm m = {1: 2}; var imm n = ;`, 2},
+		{"block closing the error", `fn f() { var imm x = {1: 2}; } f();`, 1},
+		{"valid program", `var imm m = {a: 2}; var imm f = fn() { return 1; };`, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := parseErrors(t, test.source)
+			if len(errs) != test.errors {
+				t.Errorf("got %d errors, want %d: %v", len(errs), test.errors, errs)
+			}
+		})
+	}
+}
+
+// A broken exported declaration drops the whole export from the partial
+// AST, so no export is left without a declaration.
+func TestBrokenExport(t *testing.T) {
+	for _, source := range []string{
+		`export var imm x = ; echo(1);`,
+		`export fn f(1) { return 1; } echo(1);`,
+		`export class C { fn } echo(1);`,
+	} {
+		scanner := mutex.NewScanner(source)
+		program, err := parser.ProduceAST(scanner.ScanTokens())
+		if err == nil {
+			t.Errorf("%q parsed without an error", source)
+		}
+
+		if len(program.Body) != 1 {
+			t.Errorf("%q kept %d statements, want only the one after the export", source, len(program.Body))
+		}
+		for _, statement := range program.Body {
+			if export, ok := statement.(*ast.ExportStatement); ok && export.Declaration == nil {
+				t.Errorf("%q left an export without a declaration", source)
+			}
+		}
+	}
+}
@@ -1,20 +1,65 @@
 package parser
 
 import (
+	"fmt"
+	"slices"
+
+	"github.com/caelondev/mutex/src/errors"
 	"github.com/caelondev/mutex/src/frontend/ast"
 	"github.com/caelondev/mutex/src/frontend/lexer"
 )
 
-func parseStatement(p *parser) ast.Statement {
+func parseStatement(p *parser) (statement ast.Statement) {
 	if p.isEOF() {
 		return nil
 	}
+
+	// Panic-mode recovery: record the error, skip to the next statement and
+	// drop the broken one so the rest of the file still gets parsed ---
+	startPosition := p.position
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(*errors.ParseError)
+			if !ok {
+				panic(r)
+			}
+
+			p.errs = append(p.errs, err)
+			p.synchronize(startPosition)
+			statement = nil
+		}
+	}()
+
+	return parseStatementBody(p)
+}
+
+// parseStatementBody parses a statement like parseStatement, but leaves any
+// error to unwind to the statement containing it.
+func parseStatementBody(p *parser) (statement ast.Statement) {
+	start := p.currentToken().Position()
+
+	p.nesting++
+	defer func() { p.nesting-- }()
+
+	if p.currentTokenType() == lexer.IDENTIFIER && p.peekTokenType(1) == lexer.COLON {
+		statement = parseLabeledStatement(p)
+		statement.SetSpan(p.spanFrom(start))
+		return statement
+	}
+
 	statementFunction, exists := statementLU[p.currentTokenType()]
 
 	if exists {
-		return statementFunction(p)
+		statement = statementFunction(p)
+	} else {
+		statement = parseExpressionStatement(p)
 	}
 
+	statement.SetSpan(p.spanFrom(start))
+	return statement
+}
+
+func parseExpressionStatement(p *parser) ast.Statement {
 	expression := parseExpression(p, DEFAULT_BP)
 
 	p.expect(lexer.SEMICOLON)
@@ -86,7 +131,9 @@ func parseIfStatement(p *parser) ast.Statement {
 
 		// Check if it's 'else if' or just 'else'
 		if p.currentTokenType() == lexer.IF {
+			start := p.currentToken().Position()
 			alternate = parseIfStatement(p) // recursive for else if
+			alternate.SetSpan(p.spanFrom(start))
 		} else {
 			p.expect(lexer.LEFT_BRACE)
 			alternate = parseBlock(p)
@@ -100,9 +147,20 @@ func parseIfStatement(p *parser) ast.Statement {
 	}
 }
 
+func parseBlockStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// { ... }
+	//
+
+	p.advance() // eat '{'
+	return parseBlock(p)
+}
+
 func parseBlock(p *parser) ast.Statement {
 	// Assumes LEFT_BRACE already consumed
 	var body []ast.Statement
+	start := p.previousToken().Position()
 
 	for !p.isEOF() && p.currentTokenType() != lexer.RIGHT_BRACE {
 		statement := parseStatement(p)
@@ -113,9 +171,11 @@ func parseBlock(p *parser) ast.Statement {
 
 	p.expect(lexer.RIGHT_BRACE)
 
-	return &ast.BlockStatement{
+	block := &ast.BlockStatement{
 		Body: body,
 	}
+	block.SetSpan(p.spanFrom(start))
+	return block
 }
 
 func parseWhileStatement (p *parser) ast.Statement {
@@ -124,6 +184,7 @@ func parseWhileStatement (p *parser) ast.Statement {
 	// while(condition) { ... } ---
 	//
 	
+	label := p.takeLabel()
 	p.advance() // eat while token ---
 	p.ignore(lexer.LEFT_PARENTHESIS)
 
@@ -132,9 +193,10 @@ func parseWhileStatement (p *parser) ast.Statement {
 	p.ignore(lexer.RIGHT_PARENTHESIS)
 	p.expect(lexer.LEFT_BRACE)
 
-	body := parseBlock(p)
+	body := parseLoopBody(p, label)
 	
 	return &ast.WhileStatement{
+		Label: label,
 		Condition: condition,
 		Body: body,
 	}
@@ -145,13 +207,22 @@ func parseForStatement(p *parser) ast.Statement {
 	// SYNTAX --- 
 	//
 	// for (initializer; condition; increment) { ... } ---
+	// for (var (mut | imm) name in iterable) { ... } ---
 	//
 
+	label := p.takeLabel()
 	p.advance() // Eat `for` token
 
 	p.expect(lexer.LEFT_PARENTHESIS)
 
+	// var, mut/imm and the name come before 'in' ---
+	if p.peekTokenType(3) == lexer.IN {
+		return parseForInStatement(p, label)
+	}
+
+	start := p.currentToken().Position()
 	initializer := parseVariableDeclaration(p) // Already consumes semicolon
+	initializer.SetSpan(p.spanFrom(start))
 
 	condition := parseExpression(p, DEFAULT_BP)
 	p.expect(lexer.SEMICOLON)
@@ -162,9 +233,10 @@ func parseForStatement(p *parser) ast.Statement {
 
 	// Parse body
 	p.expect(lexer.LEFT_BRACE)
-	body := parseBlock(p)
+	body := parseLoopBody(p, label)
 
 	return &ast.ForStatement{
+		Label:       label,
 		Initializer: initializer,
 		Condition:   condition,
 		Increment:   increment,
@@ -172,18 +244,64 @@ func parseForStatement(p *parser) ast.Statement {
 	}
 }
 
+func parseForInStatement(p *parser, label string) ast.Statement {
+	// Assumes `for (` already consumed
+	p.expect(lexer.VAR)
+	isMutable := p.expect(lexer.MUTABLE, lexer.IMMUTABLE).TokenType == lexer.MUTABLE
+	variable := p.expect(lexer.IDENTIFIER).Lexeme
+	p.expect(lexer.IN)
+
+	iterable := parseExpression(p, DEFAULT_BP)
+
+	p.expect(lexer.RIGHT_PARENTHESIS)
+
+	// Parse body
+	p.expect(lexer.LEFT_BRACE)
+	body := parseLoopBody(p, label)
+
+	return &ast.ForInStatement{
+		Label:     label,
+		IsMutable: isMutable,
+		Variable:  variable,
+		Iterable:  iterable,
+		Body:      body,
+	}
+}
+
 func parseFunctionDeclaration(p *parser) ast.Statement {
 	// SYNTAX ---
 	//
 	// fn name(param1, param2, ...) { ... }
 	//
-	
-	var parameters []string
+
+	// `fn (...) { ... }` without a name is a function expression ---
+	if p.peekTokenType(1) == lexer.LEFT_PARENTHESIS {
+		return parseExpressionStatement(p)
+	}
 
 	p.advance() // Eat 'fn' ---
 	name := p.expect(lexer.IDENTIFIER).Lexeme
 
-	// Parse Parameters ---
+	parameters := parseParameters(p)
+
+	p.expect(lexer.LEFT_BRACE)
+	body := parseFunctionBody(p)
+
+	return &ast.FunctionDeclaration{
+		Name: name,
+		Parameters: parameters,
+		Body: body,
+	}
+}
+
+func parseParameters(p *parser) []string {
+	// SYNTAX ---
+	//
+	// (param1, param2, ...)
+	//
+
+	var parameters []string
+
 	p.expect(lexer.LEFT_PARENTHESIS)
 
 	if p.currentTokenType() != lexer.RIGHT_PARENTHESIS {
@@ -196,14 +314,66 @@ func parseFunctionDeclaration(p *parser) ast.Statement {
 
 	p.expect(lexer.RIGHT_PARENTHESIS)
 
-	// Parse Function Body ---
+	return parameters
+}
+
+func parseFunctionBody(p *parser) ast.Statement {
+	// Assumes LEFT_BRACE already consumed
+	// Loops outside the function cannot be targeted by break/continue ---
+	enclosingLoops := p.loops
+	p.loops = nil
+	defer func() { p.loops = enclosingLoops }()
+
+	return parseBlock(p)
+}
+
+func parseClassDeclaration(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// class Name { fn init(...) { ... } fn method(...) { ... } }
+	// class Name < Superclass { ... }
+	//
+
+	p.advance() // Eat 'class' ---
+	name := p.expect(lexer.IDENTIFIER)
+
+	var superclass string
+	if p.currentTokenType() == lexer.LESS {
+		p.advance() // Eat '<' ---
+		superToken := p.expectError("Expected a superclass name after '<'", lexer.IDENTIFIER)
+		superclass = superToken.Lexeme
+
+		if superclass == name.Lexeme {
+			errors.RaiseParser(superToken, fmt.Sprintf("Class '%s' cannot inherit from itself", superclass))
+		}
+	}
+
+	p.classes = append(p.classes, superclass != "")
+	defer func() { p.classes = p.classes[:len(p.classes)-1] }()
+
 	p.expect(lexer.LEFT_BRACE)
-	body := parseBlock(p)
 
-	return &ast.FunctionDeclaration{
-		Name: name,
-		Parameters: parameters,
-		Body: body,
+	var methods []*ast.FunctionDeclaration
+	for !p.isEOF() && p.currentTokenType() != lexer.RIGHT_BRACE {
+		start := p.currentToken().Position()
+		if p.currentTokenType() != lexer.FUNCTION {
+			errors.RaiseParser(p.currentToken(), "Expected a method declaration ('fn') inside class body")
+		}
+		if p.peekTokenType(1) != lexer.IDENTIFIER {
+			errors.RaiseParser(p.tokens[p.position+1], "Expected a method name after 'fn'")
+		}
+
+		method := parseFunctionDeclaration(p).(*ast.FunctionDeclaration)
+		method.SetSpan(p.spanFrom(start))
+		methods = append(methods, method)
+	}
+
+	p.expect(lexer.RIGHT_BRACE)
+
+	return &ast.ClassDeclaration{
+		Name:       name.Lexeme,
+		Superclass: superclass,
+		Methods:    methods,
 	}
 }
 
@@ -230,6 +400,96 @@ func parseReturnStatement(p *parser) ast.Statement {
 	}
 }
 
+func parseLabeledStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// label: while (condition) { ... }
+	// label: for (initializer; condition; increment) { ... }
+	//
+
+	label := p.advance() // Eat label ---
+	p.expect(lexer.COLON)
+
+	for _, enclosing := range p.loops {
+		if enclosing == label.Lexeme {
+			errors.RaiseParser(label, fmt.Sprintf("Loop label '%s' is already used by an enclosing loop", label.Lexeme))
+		}
+	}
+
+	loopToken := p.expectError("Expected a while or for loop after a label", lexer.WHILE, lexer.FOR)
+	p.position-- // Let the loop handler consume its own keyword ---
+
+	p.label = label.Lexeme
+	return statementLU[loopToken.TokenType](p)
+}
+
+// takeLabel returns the label written before the loop being parsed, if any.
+func (p *parser) takeLabel() string {
+	label := p.label
+	p.label = ""
+	return label
+}
+
+func parseLoopBody(p *parser, label string) ast.Statement {
+	// Assumes LEFT_BRACE already consumed
+	p.loops = append(p.loops, label)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
+
+	return parseBlock(p)
+}
+
+func parseBreakStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// break;
+	// break label;
+	//
+
+	label := parseLoopControl(p)
+
+	return &ast.BreakStatement{
+		Label: label,
+	}
+}
+
+func parseContinueStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// continue;
+	// continue label;
+	//
+
+	label := parseLoopControl(p)
+
+	return &ast.ContinueStatement{
+		Label: label,
+	}
+}
+
+// parseLoopControl parses the shared tail of break and continue statements
+// and makes sure they target a loop that actually encloses them.
+func parseLoopControl(p *parser) string {
+	keyword := p.advance() // Eat 'break'/'continue' ---
+
+	if len(p.loops) == 0 {
+		errors.RaiseParser(keyword, fmt.Sprintf("Cannot use '%s' outside of a loop", keyword.Lexeme))
+	}
+
+	var label string
+	if p.currentTokenType() == lexer.IDENTIFIER {
+		labelToken := p.advance()
+		label = labelToken.Lexeme
+
+		if !slices.Contains(p.loops, label) {
+			errors.RaiseParser(labelToken, fmt.Sprintf("Cannot %s to '%s' as no enclosing loop has that label", keyword.Lexeme, label))
+		}
+	}
+
+	p.expect(lexer.SEMICOLON)
+
+	return label
+}
+
 func parseCallExpression(p *parser, left ast.Expression, bp BindingPower) ast.Expression {
 	// SYNTAX ---
 	//
@@ -263,3 +523,109 @@ func parseCallExpression(p *parser, left ast.Expression, bp BindingPower) ast.Ex
 		Arguments: args,
 	}
 }
+
+func parseImportStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// import "path/to/file" as name;
+	//
+
+	keyword := p.advance() // Eat 'import' ---
+	if p.nesting > 1 {
+		errors.RaiseParser(keyword, "'import' is only allowed at the top level of a file")
+	}
+
+	path := p.expectError("Expected a module path after 'import'", lexer.STRING).Lexeme
+	p.expectError("Expected 'as' after the module path", lexer.AS)
+	name := p.expect(lexer.IDENTIFIER).Lexeme
+
+	p.expect(lexer.SEMICOLON)
+
+	return &ast.ImportStatement{
+		Path: path,
+		Name: name,
+	}
+}
+
+func parseExportStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// export var imm name = value;
+	// export fn name(...) { ... }
+	// export class Name { ... }
+	//
+
+	keyword := p.advance() // Eat 'export' ---
+	if p.nesting > 1 {
+		errors.RaiseParser(keyword, "'export' is only allowed at the top level of a file")
+	}
+
+	switch p.currentTokenType() {
+	case lexer.VAR, lexer.CLASS:
+	case lexer.FUNCTION:
+		if p.peekTokenType(1) != lexer.LEFT_PARENTHESIS {
+			break
+		}
+		fallthrough
+	default:
+		errors.RaiseParser(p.currentToken(), "Expected a variable, function or class declaration after 'export'")
+	}
+
+	// A broken declaration fails the whole export, not just itself ---
+	return &ast.ExportStatement{
+		Declaration: parseStatementBody(p),
+	}
+}
+
+func parseThrowStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// throw value;
+	//
+
+	p.advance() // Eat 'throw' keyword ---
+	value := parseExpression(p, DEFAULT_BP)
+	p.expect(lexer.SEMICOLON)
+
+	return &ast.ThrowStatement{
+		Value: value,
+	}
+}
+
+func parseTryStatement(p *parser) ast.Statement {
+	// SYNTAX ---
+	//
+	// try { ... } catch (error) { ... }
+	// try { ... } finally { ... }
+	// try { ... } catch (error) { ... } finally { ... }
+	//
+
+	keyword := p.advance() // Eat 'try' keyword ---
+
+	p.expect(lexer.LEFT_BRACE)
+	stmt := &ast.TryStatement{
+		Body: parseBlock(p).(*ast.BlockStatement),
+	}
+
+	if p.currentTokenType() == lexer.CATCH {
+		p.advance() // Eat 'catch' ---
+		p.expect(lexer.LEFT_PARENTHESIS)
+		stmt.CatchName = p.expectError("Expected a variable name for the caught error", lexer.IDENTIFIER).Lexeme
+		p.expect(lexer.RIGHT_PARENTHESIS)
+
+		p.expect(lexer.LEFT_BRACE)
+		stmt.Catch = parseBlock(p).(*ast.BlockStatement)
+	}
+
+	if p.currentTokenType() == lexer.FINALLY {
+		p.advance() // Eat 'finally' ---
+		p.expect(lexer.LEFT_BRACE)
+		stmt.Finally = parseBlock(p).(*ast.BlockStatement)
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		errors.RaiseParser(keyword, "Expected 'catch' or 'finally' after the 'try' block")
+	}
+
+	return stmt
+}
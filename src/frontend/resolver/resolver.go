@@ -0,0 +1,360 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/frontend/ast"
+	"github.com/caelondev/mutex/src/frontend/lexer"
+)
+
+// Globals is the global scope a program will run in. It may already hold
+// variables, such as the natives or declarations from earlier REPL input.
+type Globals interface {
+	IsDeclared(name string) bool
+	IsConstant(name string) bool
+	IsNative(name string) bool // Declared by the interpreter, and so open to be declared again
+}
+
+type variable struct {
+	slot     int
+	constant bool
+	declared bool // False while the variable is hoisted ahead of its declaration
+}
+
+// scope mirrors one environment the runtime creates, so depths and slots
+// worked out here match the environments the program will run in.
+type scope struct {
+	variables map[string]*variable
+	slots     int
+	function  int // Nesting level of the function the scope belongs to
+}
+
+type resolver struct {
+	scopes   []*scope // scopes[0] is the global scope
+	globals  Globals
+	function int
+	errs     errors.ErrorList
+}
+
+// Resolve binds every variable in program to the declaration it refers to
+// and records the result on the nodes, so the runtime can reach local
+// variables by slot instead of by name. Reading a variable before its
+// declaration, declaring a name twice in one scope and assigning to an
+// immutable variable are reported as *errors.ResolveError values.
+func Resolve(program ast.BlockStatement, globals Globals) error {
+	r := &resolver{globals: globals}
+
+	r.beginScope(program.Body)
+	for _, stmt := range program.Body {
+		r.statement(stmt)
+	}
+	r.endScope()
+
+	if len(r.errs) > 0 {
+		return r.errs
+	}
+	return nil
+}
+
+func (r *resolver) statement(node ast.Statement) {
+	switch n := node.(type) {
+	case *ast.BlockStatement:
+		r.block(n)
+	case *ast.ExpressionStatement:
+		r.expression(n.Expression)
+	case *ast.VariableDeclarationStatement:
+		if n.Value != nil {
+			r.expression(n.Value)
+		}
+		n.Binding = r.declare(n.Identifier, !n.IsMutable, n.Span())
+	case *ast.IfStatement:
+		r.expression(n.Condition)
+		r.statement(n.Consequent)
+		if n.Alternate != nil {
+			r.statement(n.Alternate)
+		}
+	case *ast.WhileStatement:
+		r.expression(n.Condition)
+		r.statement(n.Body)
+	case *ast.ForStatement:
+		r.beginScope(nil)
+		r.statement(n.Initializer)
+		r.expression(n.Condition)
+		r.statement(n.Body)
+		r.expression(n.Increment)
+		r.endScope()
+	case *ast.ForInStatement:
+		r.expression(n.Iterable)
+		r.beginScope(nil)
+		r.declare(n.Variable, !n.IsMutable, n.Span())
+		r.statement(n.Body)
+		r.endScope()
+	case *ast.FunctionDeclaration:
+		n.Binding = r.declare(n.Name, true, n.Span())
+		r.resolveFunction(n.Parameters, n.Body, false, n.Span())
+	case *ast.ClassDeclaration:
+		r.classDeclaration(n)
+	case *ast.ImportStatement:
+		n.Binding = r.declare(n.Name, true, n.Span())
+	case *ast.ExportStatement:
+		r.statement(n.Declaration)
+	case *ast.ThrowStatement:
+		r.expression(n.Value)
+	case *ast.TryStatement:
+		r.block(n.Body)
+		if n.Catch != nil {
+			r.beginScope(nil)
+			r.declare(n.CatchName, false, n.Span())
+			r.block(n.Catch)
+			r.endScope()
+		}
+		if n.Finally != nil {
+			r.block(n.Finally)
+		}
+	case *ast.ReturnStatement:
+		if n.Value != nil {
+			r.expression(n.Value)
+		}
+	case *ast.BreakStatement, *ast.ContinueStatement:
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	r.beginScope(block.Body)
+	for _, stmt := range block.Body {
+		r.statement(stmt)
+	}
+	block.Slots = r.endScope()
+}
+
+func (r *resolver) classDeclaration(stmt *ast.ClassDeclaration) {
+	if stmt.Superclass != "" {
+		stmt.SuperclassBinding, _ = r.lookup(stmt.Superclass, stmt.Span())
+
+		// Methods reach the superclass through 'super' ---
+		r.beginScope(nil)
+		r.declare("super", true, stmt.Span())
+	}
+
+	for _, method := range stmt.Methods {
+		r.resolveFunction(method.Parameters, method.Body, true, method.Span())
+	}
+
+	if stmt.Superclass != "" {
+		r.endScope()
+	}
+
+	stmt.Binding = r.declare(stmt.Name, true, stmt.Span())
+}
+
+// resolveFunction resolves a function body in the scopes a call creates: one
+// holding 'this' for methods, one for the parameters, then the body block.
+func (r *resolver) resolveFunction(parameters []string, body ast.Statement, method bool, span lexer.Span) {
+	r.function++
+
+	if method {
+		r.beginScope(nil)
+		r.declare("this", true, span)
+	}
+
+	r.beginScope(nil)
+	for _, parameter := range parameters {
+		r.declare(parameter, false, span)
+	}
+	r.statement(body)
+	r.endScope()
+
+	if method {
+		r.endScope()
+	}
+
+	r.function--
+}
+
+func (r *resolver) expression(node ast.Expression) {
+	switch n := node.(type) {
+	case *ast.SymbolExpression:
+		n.Binding, _ = r.lookup(n.Value, n.Span())
+	case *ast.BinaryExpression:
+		r.expression(n.Left)
+		r.expression(n.Right)
+	case *ast.AssignmentExpression:
+		r.expression(n.NewValue)
+		if symbol, ok := n.Assignee.(*ast.SymbolExpression); ok {
+			symbol.Binding = r.assign(symbol.Value, n.Span())
+		}
+	case *ast.UnaryExpression:
+		r.expression(n.Operand)
+	case *ast.PostfixExpression:
+		if symbol, ok := n.Operand.(*ast.SymbolExpression); ok {
+			symbol.Binding = r.assign(symbol.Value, n.Span())
+		}
+	case *ast.ArrayExpression:
+		for _, element := range n.Elements {
+			r.expression(element)
+		}
+	case *ast.ArrayIndexExpression:
+		r.expression(n.Object)
+		r.expression(n.Index)
+	case *ast.ArrayIndexAssignmentExpression:
+		r.expression(n.Object)
+		r.expression(n.Index)
+		r.expression(n.NewValue)
+	case *ast.CallExpression:
+		r.expression(n.Callee)
+		for _, argument := range n.Arguments {
+			r.expression(argument)
+		}
+	case *ast.MapExpression:
+		for _, entry := range n.Entries {
+			r.expression(entry.Value)
+		}
+	case *ast.MemberExpression:
+		r.expression(n.Object)
+	case *ast.MemberAssignmentExpression:
+		r.expression(n.Object)
+		r.expression(n.NewValue)
+	case *ast.FunctionExpression:
+		r.resolveFunction(n.Parameters, n.Body, false, n.Span())
+	case *ast.ThisExpression:
+		n.Binding, _ = r.lookup("this", n.Span())
+	case *ast.SuperExpression:
+		n.Super, _ = r.lookup("super", n.Span())
+		n.This, _ = r.lookup("this", n.Span())
+	}
+}
+
+// Scopes ---
+
+// beginScope opens a scope and hoists every variable, function and class
+// declared directly in body into it. A function may then refer to a
+// declaration further down, which is what lets local functions call each
+// other regardless of their order.
+func (r *resolver) beginScope(body []ast.Statement) {
+	s := &scope{variables: map[string]*variable{}, function: r.function}
+
+	for _, stmt := range body {
+		name, constant := declaredName(stmt)
+		if _, exists := s.variables[name]; name == "" || exists {
+			continue
+		}
+
+		s.variables[name] = &variable{slot: s.slots, constant: constant}
+		s.slots++
+	}
+
+	r.scopes = append(r.scopes, s)
+}
+
+// endScope closes the innermost scope and returns how many slots it needs.
+func (r *resolver) endScope() int {
+	s := r.scopes[len(r.scopes)-1]
+	r.scopes = r.scopes[:len(r.scopes)-1]
+	return s.slots
+}
+
+func declaredName(stmt ast.Statement) (name string, constant bool) {
+	switch n := stmt.(type) {
+	case *ast.VariableDeclarationStatement:
+		return n.Identifier, !n.IsMutable
+	case *ast.FunctionDeclaration:
+		return n.Name, true
+	case *ast.ClassDeclaration:
+		return n.Name, true
+	case *ast.ImportStatement:
+		return n.Name, true
+	case *ast.ExportStatement:
+		return declaredName(n.Declaration)
+	}
+	return "", false
+}
+
+// declare declares name in the innermost scope and returns its binding.
+func (r *resolver) declare(name string, constant bool, span lexer.Span) ast.Binding {
+	depth := len(r.scopes) - 1
+	s := r.scopes[depth]
+
+	alreadyDefined := depth == 0 && r.globals.IsDeclared(name) && !r.globals.IsNative(name)
+
+	v, exists := s.variables[name]
+	if exists && v.declared {
+		alreadyDefined = true
+	}
+	if alreadyDefined {
+		r.error(span, fmt.Sprintf("Cannot declare variable \"%s\" as it is already defined", name))
+	}
+
+	if !exists {
+		v = &variable{slot: s.slots}
+		s.variables[name] = v
+		s.slots++
+	}
+	v.declared = true
+	v.constant = constant
+
+	return r.binding(depth, v)
+}
+
+// lookup finds the declaration name refers to from the innermost scope. The
+// variable is nil when name is a global the program does not declare.
+func (r *resolver) lookup(name string, span lexer.Span) (ast.Binding, *variable) {
+	for depth := len(r.scopes) - 1; depth >= 0; depth-- {
+		s := r.scopes[depth]
+
+		v, ok := s.variables[name]
+		if !ok {
+			continue
+		}
+
+		if !v.declared {
+			// Until the program redeclares it, the name means the existing global ---
+			if depth == 0 && r.globals.IsDeclared(name) {
+				return ast.Binding{}, nil
+			}
+
+			// Code in the same function runs in source order, so it can
+			// never see the declaration; a nested function may run later ---
+			if s.function == r.function {
+				r.error(span, fmt.Sprintf("Cannot use variable \"%s\" before it is declared", name))
+			}
+		}
+
+		return r.binding(depth, v), v
+	}
+
+	return ast.Binding{}, nil
+}
+
+func (r *resolver) assign(name string, span lexer.Span) ast.Binding {
+	binding, v := r.lookup(name, span)
+
+	constant := r.globals.IsConstant(name)
+	if v != nil {
+		constant = v.constant
+	}
+
+	if constant {
+		r.error(span, fmt.Sprintf("Cannot re-assign constant variable \"%s\"", name))
+	}
+
+	return binding
+}
+
+// binding describes v, declared in r.scopes[depth], as seen from the
+// innermost scope.
+func (r *resolver) binding(depth int, v *variable) ast.Binding {
+	if depth == 0 {
+		return ast.Binding{}
+	}
+
+	return ast.Binding{
+		Local: true,
+		Depth: len(r.scopes) - 1 - depth,
+		Slot:  v.slot,
+	}
+}
+
+func (r *resolver) error(span lexer.Span, message string) {
+	r.errs = append(r.errs, errors.NewResolveError(span, message))
+}
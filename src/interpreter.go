@@ -0,0 +1,241 @@
+package mutex
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/caelondev/mutex/src/frontend/ast"
+	"github.com/caelondev/mutex/src/frontend/parser"
+	"github.com/caelondev/mutex/src/frontend/resolver"
+	"github.com/caelondev/mutex/src/runtime"
+	"github.com/caelondev/mutex/src/vm"
+)
+
+// Interpreter runs Mutex source code against its own global environment.
+// Interpreters are independent of each other, so a host program may create
+// as many as it needs.
+type Interpreter struct {
+	env     *runtime.EnvironmentStruct
+	backend Backend
+	machine *vm.VM
+	modules *moduleLoader
+}
+
+// Backend selects how an Interpreter executes programs. Both backends share
+// the same values and natives and produce the same output.
+type Backend int
+
+const (
+	TreeWalker Backend = iota // Evaluate the AST directly
+	BytecodeVM                // Compile to bytecode and run it on a stack VM
+)
+
+type Option func(*Interpreter)
+
+// WithBackend selects the backend programs run on. The default is TreeWalker.
+func WithBackend(backend Backend) Option {
+	return func(i *Interpreter) {
+		i.backend = backend
+	}
+}
+
+// WithSearchPath adds directories to look for imported modules in when they
+// are not found next to the importing file.
+func WithSearchPath(dirs ...string) Option {
+	return func(i *Interpreter) {
+		i.modules.searchPath = append(i.modules.searchPath, dirs...)
+	}
+}
+
+// WithStdout redirects everything scripts print (e.g. through echo) to w.
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.env.Interpreter().Stdout = w
+	}
+}
+
+// WithStdin makes read_line read from r instead of the process's standard
+// input. A nil r gives scripts no input at all.
+func WithStdin(r io.Reader) Option {
+	return func(i *Interpreter) {
+		if r == nil {
+			i.env.Interpreter().Stdin = nil
+			return
+		}
+		i.env.Interpreter().Stdin = bufio.NewReader(r)
+	}
+}
+
+// WithReadAccess lets scripts read files inside dirs. Without it they cannot
+// read any file.
+func WithReadAccess(dirs ...string) Option {
+	return func(i *Interpreter) {
+		permissions := &i.env.Interpreter().Permissions
+		permissions.Read = append(permissions.Read, dirs...)
+	}
+}
+
+// WithWriteAccess lets scripts create and write files inside dirs. Without it
+// they cannot write any file.
+func WithWriteAccess(dirs ...string) Option {
+	return func(i *Interpreter) {
+		permissions := &i.env.Interpreter().Permissions
+		permissions.Write = append(permissions.Write, dirs...)
+	}
+}
+
+// WithMaxCallDepth limits how deeply calls may nest before a script fails
+// with a stack overflow error. The default is runtime.DefaultMaxCallDepth.
+func WithMaxCallDepth(depth int) Option {
+	return func(i *Interpreter) {
+		i.env.Interpreter().MaxCallDepth = depth
+	}
+}
+
+// WithStepLimit stops scripts with an *errors.LimitError once they have
+// made more than steps calls and loop iterations in one run.
+func WithStepLimit(steps int) Option {
+	return func(i *Interpreter) {
+		i.env.Interpreter().MaxSteps = steps
+	}
+}
+
+// WithMemoryLimit stops scripts with an *errors.LimitError once the heap has
+// grown by more than bytes during a run. The heap is the whole process's, so
+// the limit is only a rough one while other goroutines allocate.
+func WithMemoryLimit(bytes uint64) Option {
+	return func(i *Interpreter) {
+		i.env.Interpreter().MaxMemory = bytes
+	}
+}
+
+func NewInterpreter(opts ...Option) *Interpreter {
+	env := runtime.NewEnvironment(nil)
+	interpreter := &Interpreter{
+		env:     env,
+		machine: vm.New(env),
+	}
+	interpreter.modules = newModuleLoader(interpreter)
+	env.Interpreter().Importer = interpreter.modules
+
+	for _, opt := range opts {
+		opt(interpreter)
+	}
+
+	return interpreter
+}
+
+// Eval runs sourceCode in the interpreter's global environment and returns
+// the value of the last statement. Declarations persist between calls.
+func (i *Interpreter) Eval(sourceCode string) (runtime.RuntimeValue, error) {
+	return i.EvalContext(context.Background(), sourceCode)
+}
+
+// EvalContext runs sourceCode like Eval, stopping it with an
+// *errors.LimitError wrapping ctx.Err() once ctx is done.
+func (i *Interpreter) EvalContext(ctx context.Context, sourceCode string) (runtime.RuntimeValue, error) {
+	program, err := analyze(sourceCode, i.env)
+	if err != nil {
+		return nil, err
+	}
+
+	i.env.Interpreter().Begin(ctx)
+	defer i.env.Interpreter().End()
+
+	return i.execute(program, i.env, i.machine)
+}
+
+// execute runs a resolved program in env on the interpreter's backend.
+// machine is the VM whose globals are env, used by the BytecodeVM backend.
+func (i *Interpreter) execute(program ast.BlockStatement, env *runtime.EnvironmentStruct, machine *vm.VM) (runtime.RuntimeValue, error) {
+	if i.backend == BytecodeVM {
+		function, err := vm.Compile(program)
+		if err != nil {
+			return nil, err
+		}
+		return machine.Run(function)
+	}
+
+	var result runtime.RuntimeValue = runtime.NIL()
+	var err error
+	for _, stmt := range program.Body {
+		result, err = runtime.EvaluateStatement(stmt, env)
+		if err != nil {
+			return nil, err
+		}
+
+		// A top-level return ends the program ---
+		if returnValue, ok := result.(*runtime.ReturnValue); ok {
+			return returnValue.Value, nil
+		}
+	}
+
+	return result, nil
+}
+
+// Check reports every error sourceCode would fail with before running, such
+// as an assignment to an immutable variable in a branch that is rarely
+// taken, without running any of it.
+func (i *Interpreter) Check(sourceCode string) error {
+	_, err := analyze(sourceCode, i.env)
+	return err
+}
+
+// analyze scans, parses and resolves sourceCode against the global
+// environment env.
+func analyze(sourceCode string, env *runtime.EnvironmentStruct) (ast.BlockStatement, error) {
+	scanner := NewScanner(sourceCode)
+	tokens := scanner.ScanTokens()
+	if len(scanner.Errors) > 0 {
+		return ast.BlockStatement{}, scanner.Errors
+	}
+
+	program, err := parser.ProduceAST(tokens)
+	if err != nil {
+		return ast.BlockStatement{}, err
+	}
+
+	if err := resolver.Resolve(program, env); err != nil {
+		return ast.BlockStatement{}, err
+	}
+
+	return program, nil
+}
+
+// RunFile reads the file at path and evaluates it with Eval. Modules it
+// imports are looked up relative to path.
+func (i *Interpreter) RunFile(path string) (runtime.RuntimeValue, error) {
+	return i.RunFileContext(context.Background(), path)
+}
+
+// RunFileContext runs the file at path like RunFile, stopping it like
+// EvalContext once ctx is done.
+func (i *Interpreter) RunFileContext(ctx context.Context, path string) (runtime.RuntimeValue, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.evalFile(ctx, path, string(bytes))
+}
+
+// evalFile evaluates sourceCode, read from the file at path, with
+// EvalContext.
+func (i *Interpreter) evalFile(ctx context.Context, path string, sourceCode string) (runtime.RuntimeValue, error) {
+	i.env.SetModule(path)
+
+	// Modules next to the file may be imported ---
+	if root := filepath.Dir(path); !slices.Contains(i.modules.roots, root) {
+		i.modules.roots = append(i.modules.roots, root)
+	}
+
+	// The file counts as being loaded, so importing it back is a cycle ---
+	i.modules.loading = append(i.modules.loading, path)
+	defer func() { i.modules.loading = i.modules.loading[:len(i.modules.loading)-1] }()
+
+	return i.EvalContext(ctx, sourceCode)
+}
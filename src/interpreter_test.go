@@ -0,0 +1,132 @@
+package mutex
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/caelondev/mutex/src/errors"
+)
+
+// BenchmarkLoop measures the per-node cost of evaluation, which every
+// statement and expression of a script pays.
+func BenchmarkLoop(b *testing.B) {
+	const program = `
+var mut total = 0;
+for (var mut i = 0; i < 100000; i++) {
+  total = total + i * 2;
+}
+total;`
+
+	for name, backend := range backends {
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				interpreter := NewInterpreter(WithBackend(backend))
+				if _, err := interpreter.Eval(program); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestAllocationLimit checks that natives asked to build values too big for
+// the memory limit fail with an *errors.LimitError before building them.
+func TestAllocationLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		options []Option
+	}{
+		{name: "range", source: `range(0, 1000000000000);`},
+		{name: "repeat", source: `repeat("ab", 10000000000);`},
+		{name: "replace", source: `replace(repeat("a", 1000000), "a", repeat("b", 10000));`},
+		{name: "range under a memory limit", source: `range(10000000);`, options: []Option{WithMemoryLimit(64 << 20)}},
+		{name: "join under a memory limit", source: `var imm part = repeat("a", 1000000); join(map(range(100), fn(n) { return part; }), part);`, options: []Option{WithMemoryLimit(64 << 20)}},
+		{name: "repeat under a memory limit", source: `repeat("a", 100000000);`, options: []Option{WithMemoryLimit(64 << 20)}},
+		{name: "split between characters", source: `split(repeat("a", 40000000), "");`},
+	}
+
+	for name, backend := range backends {
+		for _, test := range tests {
+			t.Run(name+"/"+test.name, func(t *testing.T) {
+				options := append([]Option{WithBackend(backend)}, test.options...)
+				_, err := NewInterpreter(options...).Eval(test.source)
+
+				var limit *errors.LimitError
+				if !stderrors.As(err, &limit) {
+					t.Fatalf("got error %v, want an *errors.LimitError", err)
+				}
+			})
+		}
+	}
+
+	// A string indent counts like a number of spaces. The host builds it, so
+	// only json_stringify() can run into the limit ---
+	interpreter := NewInterpreter(WithMemoryLimit(16 << 20))
+	if err := interpreter.Define("indent", strings.Repeat(" ", 32<<20)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := interpreter.Eval(`json_stringify([[1]], indent);`)
+
+	var limit *errors.LimitError
+	if !stderrors.As(err, &limit) || !strings.Contains(err.Error(), "json_stringify()") {
+		t.Errorf("got error %v, want json_stringify() to run into the limit", err)
+	}
+}
+
+// TestLargePrograms checks that the bytecode VM runs programs too large for
+// 16-bit operands, the same as the tree walker.
+func TestLargePrograms(t *testing.T) {
+	var arguments, parameters, block, constants strings.Builder
+	for i := range 300 {
+		fmt.Fprintf(&parameters, "p%d, ", i)
+		fmt.Fprintf(&arguments, "%d, ", i)
+	}
+	for i := range 15000 {
+		fmt.Fprintf(&block, "  total = total + %d;\n", i%7)
+	}
+	for i := range 70000 {
+		fmt.Fprintf(&constants, "%d.5, ", i)
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{
+			name:   "300 arguments",
+			source: "fn f(" + strings.TrimSuffix(parameters.String(), ", ") + ") { return p0 + p299; }\necho(f(" + strings.TrimSuffix(arguments.String(), ", ") + "));",
+			output: "299\n",
+		},
+		{
+			name:   "jump over 15000 statements",
+			source: "var mut total = 0;\nfor (var mut i = 0; i < 2; i++) {\n  if (i == 1) {\n" + block.String() + "  }\n}\necho(total);",
+			output: "44997\n",
+		},
+		{
+			name:   "70000 constants",
+			source: "var imm numbers = [" + strings.TrimSuffix(constants.String(), ", ") + "];\necho(len(numbers), numbers[69999]);",
+			output: "70000 69999.5\n",
+		},
+	}
+
+	for name, backend := range backends {
+		for _, test := range tests {
+			t.Run(name+"/"+test.name, func(t *testing.T) {
+				var stdout bytes.Buffer
+				interpreter := NewInterpreter(WithBackend(backend), WithStdout(&stdout))
+				if _, err := interpreter.Eval(test.source); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if stdout.String() != test.output {
+					t.Errorf("printed %q, want %q", stdout.String(), test.output)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package mutex
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/frontend/ast"
+	"github.com/caelondev/mutex/src/runtime"
+	"github.com/caelondev/mutex/src/vm"
+)
+
+// moduleLoader loads the files import statements name. Each file is
+// evaluated once per interpreter, and every import of it shares the same
+// namespace.
+type moduleLoader struct {
+	interpreter *Interpreter
+	searchPath  []string
+	roots       []string // Directories of the files run, which their imports may read
+
+	modules map[string]*runtime.ModuleValue // Keyed by absolute path
+	sources map[string]string               // Keyed by the path errors name the file by
+	loading []string                        // Files being evaluated, innermost last
+}
+
+func newModuleLoader(interpreter *Interpreter) *moduleLoader {
+	return &moduleLoader{
+		interpreter: interpreter,
+		modules:     map[string]*runtime.ModuleValue{},
+		sources:     map[string]string{},
+	}
+}
+
+func (l *moduleLoader) Import(path string, from string) (*runtime.ModuleValue, error) {
+	file, err := l.find(path, from)
+	if err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, errors.NewRuntimeError(nil, fmt.Sprintf("Cannot import \"%s\": %v", path, err))
+	}
+
+	if module, ok := l.modules[abs]; ok {
+		return module, nil
+	}
+
+	if start := slices.IndexFunc(l.loading, func(loading string) bool { return sameFile(loading, abs) }); start >= 0 {
+		cycle := append(slices.Clone(l.loading[start:]), file)
+		return nil, errors.NewRuntimeError(nil, fmt.Sprintf("Import cycle: %s", strings.Join(cycle, " -> ")))
+	}
+
+	module, err := l.load(file)
+	if err != nil {
+		return nil, err
+	}
+
+	l.modules[abs] = module
+	return module, nil
+}
+
+// find returns the file path names, looking next to the importing file from
+// first and then in the search path. Paths starting with "./" or "../" are
+// only looked up next to the importing file.
+//
+// Only files inside the search path, the directories of the files run and
+// the directories scripts may read are imported, so an import cannot read a
+// file the file natives would refuse. Candidates outside them are not even
+// looked at.
+func (l *moduleLoader) find(path string, from string) (string, error) {
+	if filepath.Ext(path) == "" {
+		path += ".lang"
+	}
+
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(filepath.Dir(from), path)}
+
+		if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+			for _, dir := range l.searchPath {
+				candidates = append(candidates, filepath.Join(dir, path))
+			}
+		}
+	}
+
+	roots := slices.Concat(l.roots, l.searchPath, l.interpreter.env.Interpreter().Permissions.Read)
+
+	denied := false
+	for _, candidate := range candidates {
+		if !runtime.Allowed(roots, candidate) {
+			denied = true
+			continue
+		}
+
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	if denied {
+		return "", errors.NewRuntimeError(nil, fmt.Sprintf("No read access to module \"%s\"", path))
+	}
+	return "", errors.NewRuntimeError(nil, fmt.Sprintf("Cannot find module \"%s\"", path))
+}
+
+// load evaluates file in a global environment of its own and returns the
+// namespace of its exports.
+func (l *moduleLoader) load(file string) (*runtime.ModuleValue, error) {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.NewRuntimeError(nil, fmt.Sprintf("Cannot read module: %v", err))
+	}
+
+	sourceCode := string(bytes)
+	l.sources[file] = sourceCode
+
+	env := runtime.NewModuleEnvironment(l.interpreter.env, file)
+	program, err := analyze(sourceCode, env)
+	if err != nil {
+		return nil, &errors.ModuleError{Path: file, Err: err}
+	}
+
+	l.loading = append(l.loading, file)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	if _, err := l.interpreter.execute(program, env, vm.New(env)); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	return runtime.MODULE(name, file, exportedNames(program), env), nil
+}
+
+func exportedNames(program ast.BlockStatement) []string {
+	var names []string
+	for _, stmt := range program.Body {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+
+		switch declaration := export.Declaration.(type) {
+		case *ast.VariableDeclarationStatement:
+			names = append(names, declaration.Identifier)
+		case *ast.FunctionDeclaration:
+			names = append(names, declaration.Name)
+		case *ast.ClassDeclaration:
+			names = append(names, declaration.Name)
+		}
+	}
+	return names
+}
+
+func sameFile(file string, abs string) bool {
+	fileAbs, err := filepath.Abs(file)
+	return err == nil && fileAbs == abs
+}
@@ -0,0 +1,80 @@
+package mutex
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestImportAccess checks that imports only read files inside the directory
+// of the file run, the search path and the directories scripts may read.
+func TestImportAccess(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app/local.lang":  `export var imm value = "local";`,
+		"lib/shared.lang": `export var imm value = "shared";`,
+		"secret/key.yml":  "api_key: sk-live-123\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret", "key.yml"), filepath.Join(root, "app", "link.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	lib := filepath.Join(root, "lib")
+	tests := []struct {
+		name    string
+		source  string
+		options []Option
+		output  string // Printed when the import is allowed
+		err     string // Part of the error when it is not
+	}{
+		{name: "next to the file", source: `import "./local" as m; echo(m.value);`, output: "\"local\"\n"},
+		{name: "absolute path outside", source: `import "` + filepath.Join(root, "secret", "key.yml") + `" as m;`, err: "No read access"},
+		{name: "relative path outside", source: `import "../lib/shared" as m;`, err: "No read access"},
+		{name: "symbolic link outside", source: `import "./link.yml" as m;`, err: "No read access"},
+		{name: "read access", source: `import "../lib/shared" as m; echo(m.value);`, options: []Option{WithReadAccess(lib)}, output: "\"shared\"\n"},
+		{name: "search path", source: `import "shared" as m; echo(m.value);`, options: []Option{WithSearchPath(lib)}, output: "\"shared\"\n"},
+	}
+
+	for name, backend := range backends {
+		for _, test := range tests {
+			t.Run(name+"/"+test.name, func(t *testing.T) {
+				main := filepath.Join(root, "app", "main.lang")
+				if err := os.WriteFile(main, []byte(test.source), 0o644); err != nil {
+					t.Fatal(err)
+				}
+
+				var stdout bytes.Buffer
+				options := append([]Option{WithBackend(backend), WithStdout(&stdout)}, test.options...)
+				_, err := NewInterpreter(options...).RunFile(main)
+
+				if test.err == "" {
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					if stdout.String() != test.output {
+						t.Errorf("printed %q, want %q", stdout.String(), test.output)
+					}
+					return
+				}
+
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want one containing %q", err, test.err)
+				}
+				if strings.Contains(err.Error(), "sk-live") {
+					t.Errorf("error leaks the file: %v", err)
+				}
+			})
+		}
+	}
+}
@@ -1,87 +1,192 @@
-package src
+package mutex
 
 import (
-	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/caelondev/mutex/src/errors"
-	"github.com/caelondev/mutex/src/frontend/parser"
 	"github.com/caelondev/mutex/src/runtime"
 	// "github.com/sanity-io/litter"
 )
 
 type Mutex struct {
-	hadError bool
+	interpreter *Interpreter
+	hadError    bool
+	timeout     time.Duration // How long each run may take, zero for no limit
 }
 
-var mutex = Mutex{}
-var env = runtime.NewEnvironment(nil)
-
 func Main() {
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: mutex <filepath>")
+	backendName := flag.String("backend", "tree", "how programs run: 'tree' walks the AST, 'vm' compiles to bytecode")
+	searchPath := flag.String("path", "", "directories to look for imported modules in, separated by '"+string(filepath.ListSeparator)+"'")
+	allowRead := flag.String("allow-read", "", "directories scripts may read files in, separated by '"+string(filepath.ListSeparator)+"'")
+	allowWrite := flag.String("allow-write", "", "directories scripts may write files in, separated by '"+string(filepath.ListSeparator)+"'")
+	maxCallDepth := flag.Int("max-call-depth", runtime.DefaultMaxCallDepth, "how deeply calls may nest before a stack overflow error")
+	maxSteps := flag.Int("max-steps", 0, "how many calls and loop iterations a run may make, 0 for no limit")
+	timeout := flag.Duration("timeout", 0, "how long a run may take, such as 5s, 0 for no limit")
+	flag.Usage = func() {
+		fmt.Println("Usage: mutex [--backend=tree|vm] [--path=dirs] [--allow-read=dirs] [--allow-write=dirs] [--max-call-depth=n] [--max-steps=n] [--timeout=duration] [filepath]")
+		fmt.Println("       mutex check <filepath>...")
+	}
+	flag.Parse()
+
+	if flag.Arg(0) == "check" {
+		if flag.NArg() == 1 {
+			flag.Usage()
+			os.Exit(64)
+		}
+		os.Exit(checkFiles(flag.Args()[1:]))
+	}
+
+	backend, ok := backends[*backendName]
+	if flag.NArg() > 1 || !ok {
+		flag.Usage()
 		os.Exit(64)
 	}
 
-	if len(os.Args) == 2 {
-		mutex.runFile(os.Args[1])
+	mutex := &Mutex{
+		interpreter: NewInterpreter(
+			WithBackend(backend),
+			WithSearchPath(filepath.SplitList(*searchPath)...),
+			WithReadAccess(filepath.SplitList(*allowRead)...),
+			WithWriteAccess(filepath.SplitList(*allowWrite)...),
+			WithMaxCallDepth(*maxCallDepth),
+			WithStepLimit(*maxSteps),
+		),
+		timeout: *timeout,
+	}
+
+	if flag.NArg() == 1 {
+		if err := mutex.runFile(flag.Arg(0)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(66)
+		}
 	} else {
 		mutex.runRepl()
 	}
 }
 
+var backends = map[string]Backend{
+	"tree": TreeWalker,
+	"vm":   BytecodeVM,
+}
+
+// checkFiles reports the errors each file would fail with before running,
+// without running any of them, and returns the process exit code.
+func checkFiles(paths []string) int {
+	code := 0
+	for _, path := range paths {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 66
+			continue
+		}
+
+		// Each file is checked against a fresh global environment ---
+		m := &Mutex{interpreter: NewInterpreter()}
+		if err := m.interpreter.Check(string(bytes)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s:\n", path)
+			m.reportError(string(bytes), err)
+			code = max(code, exitCode(err))
+		}
+	}
+
+	return code
+}
+
 func (m *Mutex) runFile(path string) error {
 	bytes, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	mutex.run(string(bytes))
+	err = m.run(path, string(bytes))
 
 	if m.hadError {
-		os.Exit(65)
+		os.Exit(exitCode(err))
 	}
 
 	return nil
 }
 
-func (m *Mutex) runRepl() {
-	input := bufio.NewScanner(os.Stdin)
-
-	for {
-		fmt.Printf(">> ")
-		if ok := input.Scan(); !ok {
-			break
-		}
-
-		line := input.Text()
-
-		if line == "@exit" {
-			m.Exit(0)
-		}
-
-		mutex.run(line)
-		mutex.hadError = false
+// run evaluates sourceCode, read from the file at path or typed into the
+// REPL when path is empty, and prints its result.
+func (m *Mutex) run(path string, sourceCode string) error {
+	ctx := context.Background()
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
 	}
-}
-
-func (m *Mutex) run(sourceCode string) {
-	scanner := NewScanner(sourceCode)
-	tokens := scanner.ScanTokens()
-	ast := parser.ProduceAST(tokens)
 
 	var result runtime.RuntimeValue
-	for _, stmt := range ast.Body {
-		result = runtime.EvaluateStatement(stmt, env)
+	var err error
+	if path != "" {
+		result, err = m.interpreter.evalFile(ctx, path, sourceCode)
+	} else {
+		result, err = m.interpreter.EvalContext(ctx, sourceCode)
+	}
+	if err != nil {
+		m.reportError(sourceCode, err)
+		return err
 	}
 
 	fmt.Printf("%v\n\n", result)
 	// litter.Dump(ast)
+	return nil
 }
 
-func (m *Mutex) ReportError(line int, message string) {
-	mutex.Report(line, "Report", message)
+func (m *Mutex) reportError(sourceCode string, err error) {
+	m.hadError = true
+
+	switch e := err.(type) {
+	case errors.ErrorList:
+		for _, err := range e {
+			m.reportError(sourceCode, err)
+		}
+	case *errors.ParseError:
+		errors.ReportSource(sourceCode, e.Span, "Parser", e.Message)
+	case *errors.ResolveError:
+		errors.ReportSource(sourceCode, e.Span, "Resolver", e.Message)
+	case *errors.ModuleError:
+		fmt.Fprintf(os.Stderr, "In module %s:\n", e.Path)
+		m.reportError(m.interpreter.modules.sources[e.Path], e.Err)
+	case *errors.RuntimeError:
+		// Errors raised by code of an imported module point into its file ---
+		if moduleSource, ok := m.interpreter.modules.sources[e.File]; ok {
+			fmt.Fprintf(os.Stderr, "In module %s:\n", e.File)
+			sourceCode = moduleSource
+		}
+		errors.ReportSource(sourceCode, e.Span, "Interpreter", e.Message)
+
+		// A single frame would only repeat the location above ---
+		if len(e.Trace) > 1 {
+			fmt.Fprintln(os.Stderr, "Traceback (most recent call first):")
+			for _, line := range strings.Split(e.Traceback(), "\n") {
+				fmt.Fprintf(os.Stderr, "    %s\n", line)
+			}
+		}
+	case *errors.LimitError:
+		// The limit is hit by the run as a whole, not by one line ---
+		errors.ReportMessage("Limit", e.Error())
+	default:
+		m.Report(0, "Mutex", err.Error())
+	}
+}
+
+// exitCode maps an error returned by run to the process exit code.
+func exitCode(err error) int {
+	switch err.(type) {
+	case *errors.RuntimeError, *errors.LimitError:
+		return 70
+	default:
+		return 65
+	}
 }
 
 func (m *Mutex) Report(line int, where, message string) {
@@ -94,7 +199,3 @@ func (m *Mutex) Exit(code int) {
 	fmt.Printf("\n[Process exited with code: %d]\n", code)
 	os.Exit(code)
 }
-
-func GetMutex() *Mutex {
-	return &mutex
-}
@@ -0,0 +1,171 @@
+package mutex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/caelondev/mutex/src/repl"
+)
+
+func (m *Mutex) runRepl() {
+	// Shared with read_line, so neither buffers input meant for the other ---
+	input := m.interpreter.env.Interpreter().Stdin
+	readLine, history := lineReader(input)
+
+	for {
+		source, err := readEntry(readLine)
+		if err == repl.ErrInterrupted {
+			continue
+		}
+		if err != nil {
+			break
+		}
+
+		// The whole entry is kept, so recalling it brings back every line ---
+		if history != nil {
+			if err := history.Add(source); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot save history: %v\n", err)
+			}
+		}
+
+		if strings.TrimSpace(source) == "@exit" {
+			m.Exit(0)
+		}
+
+		m.run("", source)
+		m.hadError = false
+	}
+}
+
+// lineReader returns how the REPL reads a line after showing a prompt: with
+// the line editor when standard input is a terminal, and as plain lines from
+// input otherwise. The history entries are recalled from is nil for input
+// that is not a terminal.
+func lineReader(input *bufio.Reader) (func(prompt string) (string, error), *repl.History) {
+	if editor, ok := repl.NewTerminalEditor(os.Stdin, input, os.Stdout); ok {
+		// Without a usable history file the history only lasts the session ---
+		if path := historyPath(); path != "" {
+			history, err := repl.LoadHistory(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot load history: %v\n", err)
+			} else {
+				editor.History = history
+			}
+		}
+
+		return editor.ReadLine, editor.History
+	}
+
+	return func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		line, err := input.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}, nil
+}
+
+// historyPath is the file the REPL keeps its history in: $MUTEX_HISTORY, or
+// .mutex_history in the home directory.
+func historyPath() string {
+	if path := os.Getenv("MUTEX_HISTORY"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mutex_history")
+}
+
+// readEntry reads lines until they make up a complete entry, prompting for
+// more while a bracket, backtick string or block comment is left open.
+// Input that ends partway through an entry returns what was read, so it is
+// reported like any other incomplete program.
+func readEntry(readLine func(prompt string) (string, error)) (string, error) {
+	var lines []string
+	prompt := ">> "
+
+	for {
+		line, err := readLine(prompt)
+		if err == io.EOF && len(lines) > 0 {
+			return strings.Join(lines, "\n"), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		lines = append(lines, line)
+		source := strings.Join(lines, "\n")
+		if !incomplete(source) {
+			return source, nil
+		}
+		prompt = ".. "
+	}
+}
+
+// incomplete reports whether source stops partway through something that
+// must be closed: a bracket, a backtick string or a block comment.
+func incomplete(source string) bool {
+	depth := 0
+	code := []rune(source)
+
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '"', '\'':
+			// Quoted strings cannot span lines, so the line ends them either way ---
+			for i+1 < len(code) && code[i+1] != c && code[i+1] != '\n' {
+				i++
+			}
+			i++
+		case '`':
+			end := find(code, i+1, "`")
+			if end < 0 {
+				return true
+			}
+			i = end
+		case '/':
+			if i+1 >= len(code) {
+				continue
+			}
+
+			switch code[i+1] {
+			case '/':
+				for i < len(code) && code[i] != '\n' {
+					i++
+				}
+			case '*':
+				end := find(code, i+2, "*/")
+				if end < 0 {
+					return true
+				}
+				i = end + 1
+			}
+		}
+	}
+
+	return depth > 0
+}
+
+// find returns the index of the first text in code at or after from, or -1
+// when there is none.
+func find(code []rune, from int, text string) int {
+	target := []rune(text)
+	for i := from; i+len(target) <= len(code); i++ {
+		if slices.Equal(code[i:i+len(target)], target) {
+			return i
+		}
+	}
+	return -1
+}
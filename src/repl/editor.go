@@ -0,0 +1,410 @@
+// Package repl reads the lines typed into the REPL, letting the user move
+// around and edit them, recall earlier lines and search through them.
+//
+// The editor works on a plain stream of key bytes, such as a terminal in raw
+// mode produces, so it can be driven by any io.Reader.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by ReadLine when the user pressed Ctrl-C,
+// abandoning the line.
+var ErrInterrupted = errors.New("interrupted")
+
+// Editor reads lines from input, echoing and redrawing them on output as
+// they are edited.
+type Editor struct {
+	History *History
+
+	input    *bufio.Reader
+	output   io.Writer
+	terminal *os.File // Put into raw mode while a line is read, nil when input is not a terminal
+	rows     int      // Rows below the first that the line took up when last drawn
+}
+
+// NewEditor returns an editor interpreting the key bytes read from input.
+func NewEditor(input *bufio.Reader, output io.Writer) *Editor {
+	return &Editor{History: &History{}, input: input, output: output}
+}
+
+// NewTerminalEditor returns an editor for the terminal the file terminal is,
+// which input reads from. It reports false when terminal is not a terminal
+// that can be put into raw mode, as when input comes from a pipe.
+func NewTerminalEditor(terminal *os.File, input *bufio.Reader, output io.Writer) (*Editor, bool) {
+	if !isTerminal(terminal) {
+		return nil, false
+	}
+
+	editor := NewEditor(input, output)
+	editor.terminal = terminal
+	return editor, true
+}
+
+// Keys ---
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+
+	// Keys sent as escape sequences, numbered past every character ---
+	keyUp = unicode.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyForwardDelete
+	keyUnknown
+)
+
+// readKey reads one key press, decoding the escape sequences of the arrow,
+// home, end and delete keys.
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.input.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	// A lone escape has nothing buffered after it ---
+	if e.input.Buffered() == 0 {
+		return keyEscape, nil
+	}
+
+	introducer, _, err := e.input.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if introducer != '[' && introducer != 'O' {
+		return keyUnknown, nil
+	}
+
+	// Parameters, then the final byte that names the key ---
+	var parameters strings.Builder
+	for {
+		c, _, err := e.input.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if c < '0' || c > '?' {
+			return escapeKey(parameters.String(), c), nil
+		}
+		parameters.WriteRune(c)
+	}
+}
+
+func escapeKey(parameters string, final rune) rune {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch parameters {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyForwardDelete
+		}
+	}
+	return keyUnknown
+}
+
+// Editing ---
+
+// line is the state of the line being edited.
+type line struct {
+	text   []rune
+	cursor int
+
+	recalled int    // Index in the history of the line shown, len(entries) for the new line
+	draft    []rune // The new line, kept while the history is being browsed
+}
+
+// ReadLine shows prompt and returns the line the user typed, without its
+// line ending. It returns io.EOF when the input ends or the user pressed
+// Ctrl-D on an empty line, and ErrInterrupted when they pressed Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.terminal != nil {
+		restore, err := makeRaw(e.terminal)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	l := &line{recalled: len(e.History.entries)}
+	e.rows = 0
+	e.refresh(prompt, l)
+
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			if err == io.EOF && len(l.text) > 0 {
+				break // The last line of input needs no line ending ---
+			}
+			e.newline()
+			return "", err
+		}
+
+		switch key {
+		case keyEnter, keyLineFeed:
+			return e.finish(prompt, l), nil
+		case keyCtrlC:
+			fmt.Fprint(e.output, "^C")
+			e.newline()
+			return "", ErrInterrupted
+		case keyCtrlD:
+			if len(l.text) == 0 {
+				e.newline()
+				return "", io.EOF
+			}
+			l.deleteForward()
+		case keyCtrlR:
+			submit, err := e.search(l)
+			if err != nil {
+				e.newline()
+				return "", err
+			}
+			if submit {
+				return e.finish(prompt, l), nil
+			}
+		case keyCtrlL:
+			fmt.Fprint(e.output, "\x1b[H\x1b[2J")
+		default:
+			e.edit(key, l)
+		}
+
+		e.refresh(prompt, l)
+	}
+
+	return e.finish(prompt, l), nil
+}
+
+// edit applies a key that changes the line or moves around it.
+func (e *Editor) edit(key rune, l *line) {
+	switch key {
+	case keyLeft, keyCtrlB:
+		l.cursor = max(l.cursor-1, 0)
+	case keyRight, keyCtrlF:
+		l.cursor = min(l.cursor+1, len(l.text))
+	case keyHome, keyCtrlA:
+		l.cursor = 0
+	case keyEnd, keyCtrlE:
+		l.cursor = len(l.text)
+	case keyBackspace, keyDelete:
+		if l.cursor > 0 {
+			l.text = append(l.text[:l.cursor-1], l.text[l.cursor:]...)
+			l.cursor--
+		}
+	case keyForwardDelete:
+		l.deleteForward()
+	case keyCtrlK:
+		l.text = l.text[:l.cursor]
+	case keyCtrlU:
+		l.text = l.text[l.cursor:]
+		l.cursor = 0
+	case keyCtrlW:
+		start := l.cursor
+		for start > 0 && unicode.IsSpace(l.text[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(l.text[start-1]) {
+			start--
+		}
+		l.text = append(l.text[:start], l.text[l.cursor:]...)
+		l.cursor = start
+	case keyUp, keyCtrlP:
+		e.recall(l, l.recalled-1)
+	case keyDown, keyCtrlN:
+		e.recall(l, l.recalled+1)
+	case keyTab:
+		l.insert("    ")
+	default:
+		if unicode.IsPrint(key) {
+			l.insert(string(key))
+		}
+	}
+}
+
+// recall shows the history entry at index in place of the line, or the new
+// line once index moves past the newest entry.
+func (e *Editor) recall(l *line, index int) {
+	entries := e.History.entries
+	if index < 0 || index > len(entries) {
+		return
+	}
+
+	if l.recalled == len(entries) {
+		l.draft = l.text
+	}
+
+	l.recalled = index
+	if index == len(entries) {
+		l.text = l.draft
+	} else {
+		l.text = []rune(entries[index])
+	}
+	l.cursor = len(l.text)
+}
+
+func (l *line) insert(text string) {
+	inserted := []rune(text)
+	l.text = append(l.text[:l.cursor], append(inserted, l.text[l.cursor:]...)...)
+	l.cursor += len(inserted)
+}
+
+func (l *line) deleteForward() {
+	if l.cursor < len(l.text) {
+		l.text = append(l.text[:l.cursor], l.text[l.cursor+1:]...)
+	}
+}
+
+// search runs a reverse incremental search through the history, started by
+// Ctrl-R. Typing narrows the search and Ctrl-R again finds an older match.
+// Enter submits the match, reported as true; Ctrl-G and Escape give up and
+// keep the line as it was; any other key leaves the match to be edited.
+func (e *Editor) search(l *line) (submit bool, err error) {
+	var query []rune
+	match := len(e.History.entries)
+
+	// find looks for the query in the entries older than from ---
+	find := func(from int) bool {
+		for index := from - 1; index >= 0; index-- {
+			if strings.Contains(e.History.entries[index], string(query)) {
+				match = index
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		found := ""
+		if match < len(e.History.entries) {
+			found = e.History.entries[match]
+		}
+		e.draw(fmt.Sprintf("(reverse-i-search)`%s': ", string(query)), []rune(found), len([]rune(found)))
+
+		key, err := e.readKey()
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case key == keyCtrlR:
+			find(match)
+			continue
+		case key == keyBackspace || key == keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = len(e.History.entries)
+				find(match)
+			}
+			continue
+		case key == keyCtrlG || key == keyEscape:
+			return false, nil
+		case key < keyUp && unicode.IsPrint(key):
+			// The current match is looked at again, as it may hold the longer query too ---
+			query = append(query, key)
+			if !find(min(match+1, len(e.History.entries))) {
+				query = query[:len(query)-1] // Nothing holds the longer query, so the key is ignored
+			}
+			continue
+		}
+
+		if match < len(e.History.entries) {
+			l.text = []rune(found)
+			l.cursor = len(l.text)
+			l.recalled = match
+		}
+		if key == keyEnter || key == keyLineFeed {
+			return true, nil
+		}
+
+		e.edit(key, l)
+		return false, nil
+	}
+}
+
+// Output ---
+
+// refresh redraws the line and puts the cursor back where it is in the line.
+func (e *Editor) refresh(prompt string, l *line) {
+	e.draw(prompt, l.text, l.cursor)
+}
+
+// draw replaces what was drawn last with prompt and text, and puts the cursor
+// at index cursor of text. A line recalled from a multi-line entry holds
+// newlines; the rows after the first are indented to line up with the first.
+func (e *Editor) draw(prompt string, text []rune, cursor int) {
+	if e.rows > 0 {
+		fmt.Fprintf(e.output, "\x1b[%dA", e.rows)
+	}
+
+	indent := strings.Repeat(" ", len([]rune(prompt)))
+	rows := strings.Split(string(text), "\n")
+	fmt.Fprintf(e.output, "\r\x1b[J%s%s", prompt, strings.Join(rows, "\r\n"+indent))
+	e.rows = len(rows) - 1
+
+	// Back up to the row and column of the cursor ---
+	start := cursor
+	for start > 0 && text[start-1] != '\n' {
+		start--
+	}
+	row, column := strings.Count(string(text[:start]), "\n"), cursor-start
+	if up := e.rows - row; up > 0 {
+		fmt.Fprintf(e.output, "\x1b[%dA", up)
+	}
+	fmt.Fprint(e.output, "\r")
+	if right := len(indent) + column; right > 0 {
+		fmt.Fprintf(e.output, "\x1b[%dC", right)
+	}
+}
+
+// finish moves the cursor past the end of the line and returns its text.
+func (e *Editor) finish(prompt string, l *line) string {
+	l.cursor = len(l.text)
+	e.refresh(prompt, l)
+	e.newline()
+	return string(l.text)
+}
+
+func (e *Editor) newline() {
+	fmt.Fprint(e.output, "\r\n")
+}
@@ -0,0 +1,121 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Key sequences a terminal in raw mode sends ---
+const (
+	up        = "\x1b[A"
+	down      = "\x1b[B"
+	right     = "\x1b[C"
+	left      = "\x1b[D"
+	home      = "\x1b[H"
+	end       = "\x1b[F"
+	delete    = "\x1b[3~"
+	backspace = "\x7f"
+	ctrlA     = "\x01"
+	ctrlC     = "\x03"
+	ctrlD     = "\x04"
+	ctrlG     = "\x07"
+	ctrlK     = "\x0b"
+	ctrlR     = "\x12"
+	ctrlU     = "\x15"
+	ctrlW     = "\x17"
+	enter     = "\r"
+)
+
+func TestReadLine(t *testing.T) {
+	history := []string{"echo(1);", "var imm x = 2;", "fn f() {\n  return 3;\n}"}
+
+	tests := []struct {
+		name  string
+		keys  string
+		lines []string // One per ReadLine call
+		err   error    // Returned by the call after the last line
+	}{
+		{name: "typing", keys: "echo(1);" + enter, lines: []string{"echo(1);"}, err: io.EOF},
+		{name: "line feed", keys: "a\nb\n", lines: []string{"a", "b"}, err: io.EOF},
+		{name: "backspace", keys: "abc" + backspace + backspace + "x" + enter, lines: []string{"ax"}, err: io.EOF},
+		{name: "insert after moving left", keys: "ac" + left + "b" + enter, lines: []string{"abc"}, err: io.EOF},
+		{name: "right stops at the end", keys: "ab" + right + right + "c" + enter, lines: []string{"abc"}, err: io.EOF},
+		{name: "home and end", keys: "bc" + home + "a" + end + "d" + enter, lines: []string{"abcd"}, err: io.EOF},
+		{name: "ctrl-a", keys: "bc" + ctrlA + "a" + enter, lines: []string{"abc"}, err: io.EOF},
+		{name: "forward delete", keys: "abc" + home + delete + enter, lines: []string{"bc"}, err: io.EOF},
+		{name: "ctrl-w deletes a word", keys: "var imm  x" + ctrlW + "y" + enter, lines: []string{"var imm  y"}, err: io.EOF},
+		{name: "ctrl-w skips spaces", keys: "one two  " + ctrlW + enter, lines: []string{"one "}, err: io.EOF},
+		{name: "ctrl-k deletes to the end", keys: "abcdef" + left + left + left + ctrlK + enter, lines: []string{"abc"}, err: io.EOF},
+		{name: "ctrl-u deletes to the start", keys: "abcdef" + left + left + ctrlU + enter, lines: []string{"ef"}, err: io.EOF},
+		{name: "up recalls the newest", keys: up + enter, lines: []string{history[2]}, err: io.EOF},
+		{name: "up twice", keys: up + up + enter, lines: []string{"var imm x = 2;"}, err: io.EOF},
+		{name: "up stops at the oldest", keys: up + up + up + up + up + enter, lines: []string{"echo(1);"}, err: io.EOF},
+		{name: "down restores the draft", keys: "draft" + up + up + down + down + enter, lines: []string{"draft"}, err: io.EOF},
+		{name: "recalled line is editable", keys: up + up + backspace + backspace + "3;" + enter, lines: []string{"var imm x = 3;"}, err: io.EOF},
+		{name: "search submits the match", keys: ctrlR + "imm" + enter, lines: []string{"var imm x = 2;"}, err: io.EOF},
+		{name: "search again finds an older match", keys: ctrlR + "(" + ctrlR + enter, lines: []string{"echo(1);"}, err: io.EOF},
+		{name: "search ignores keys matching nothing", keys: ctrlR + "echoz" + enter, lines: []string{"echo(1);"}, err: io.EOF},
+		{name: "search backspace widens the query", keys: ctrlR + "echo" + backspace + backspace + backspace + backspace + "r" + enter, lines: []string{history[2]}, err: io.EOF},
+		{name: "search leaves the match to edit", keys: ctrlR + "echo" + end + "!" + enter, lines: []string{"echo(1);!"}, err: io.EOF},
+		{name: "ctrl-g gives up the search", keys: "draft" + ctrlR + "echo" + ctrlG + enter, lines: []string{"draft"}, err: io.EOF},
+		{name: "eof on a partial line", keys: "echo(1)", lines: []string{"echo(1)"}, err: io.EOF},
+		{name: "eof on an empty line", keys: "", err: io.EOF},
+		{name: "eof during a search", keys: ctrlR + "ec", err: io.EOF},
+		{name: "ctrl-d on an empty line", keys: ctrlD + "ignored" + enter, err: io.EOF},
+		{name: "ctrl-d deletes forward", keys: "ab" + home + ctrlD + enter, lines: []string{"b"}, err: io.EOF},
+		{name: "ctrl-c abandons the line", keys: "abc" + ctrlC, err: ErrInterrupted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output strings.Builder
+			editor := NewEditor(bufio.NewReader(strings.NewReader(test.keys)), &output)
+			editor.History = &History{entries: slices.Clone(history)}
+
+			var lines []string
+			for {
+				line, err := editor.ReadLine(">> ")
+				if err != nil {
+					if err != test.err {
+						t.Errorf("got error %v, want %v", err, test.err)
+					}
+					break
+				}
+				lines = append(lines, line)
+			}
+
+			if !slices.Equal(lines, test.lines) {
+				t.Errorf("got lines %q, want %q", lines, test.lines)
+			}
+			if !slices.Equal(editor.History.entries, history) {
+				t.Errorf("editing changed the history to %q", editor.History.entries)
+			}
+		})
+	}
+}
+
+// TestDrawMultiLine checks that a recalled multi-line entry is drawn with its
+// rows lined up under the prompt, and cleared as a whole when redrawn.
+func TestDrawMultiLine(t *testing.T) {
+	var output strings.Builder
+	editor := NewEditor(bufio.NewReader(strings.NewReader(up+backspace+enter)), &output)
+	editor.History = &History{entries: []string{"{\n  1;\n}"}}
+
+	line, err := editor.ReadLine(">> ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "{\n  1;\n" {
+		t.Errorf("got line %q", line)
+	}
+
+	if !strings.Contains(output.String(), ">> {\r\n     1;\r\n   }") {
+		t.Errorf("rows are not lined up under the prompt: %q", output.String())
+	}
+	if !strings.Contains(output.String(), "\x1b[2A\r\x1b[J>> {\r\n     1;\r\n   ") {
+		t.Errorf("the entry is not cleared before it is redrawn: %q", output.String())
+	}
+}
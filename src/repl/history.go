@@ -0,0 +1,114 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// MaxHistory is how many lines a history keeps; older lines are dropped.
+const MaxHistory = 1000
+
+// History is the entries entered so far, oldest first. An entry may span
+// several lines, like a function typed over many. A history loaded from a
+// file appends every entry added to it to that file, so the entries outlive
+// the process.
+type History struct {
+	entries []string
+	file    string
+}
+
+// LoadHistory reads the history kept in the file at path. A missing file is
+// an empty history that will be created by the first line added.
+func LoadHistory(path string) (*History, error) {
+	h := &History{file: path}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Read by line with no length limit, as one entry may be a whole pasted
+	// script ---
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if entry := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"); entry != "" {
+			h.entries = append(h.entries, decode(entry))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep the file from growing forever ---
+	if len(h.entries) > MaxHistory {
+		h.entries = h.entries[len(h.entries)-MaxHistory:]
+		if err := h.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// Add appends entry to the history, unless it is blank or repeats the entry
+// before it.
+func (h *History) Add(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return nil
+	}
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > MaxHistory {
+		h.entries = h.entries[1:]
+	}
+
+	if h.file == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(encode(entry) + "\n")
+	return err
+}
+
+func (h *History) rewrite() error {
+	var contents strings.Builder
+	for _, entry := range h.entries {
+		contents.WriteString(encode(entry) + "\n")
+	}
+	return os.WriteFile(h.file, []byte(contents.String()), 0o600)
+}
+
+// The file holds one entry per line, so the line breaks of an entry are
+// written as \n and its backslashes as \\ ---
+
+var (
+	encoder = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	decoder = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+)
+
+func encode(entry string) string {
+	return encoder.Replace(entry)
+}
+
+func decode(line string) string {
+	return decoder.Replace(line)
+}
@@ -0,0 +1,124 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	h := &History{}
+	for _, entry := range []string{"a", "", "  ", "a", "b", "a", "fn f() {\n  return 1;\n}"} {
+		if err := h.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"a", "b", "a", "fn f() {\n  return 1;\n}"}
+	if !slices.Equal(h.entries, want) {
+		t.Errorf("got %q, want %q", h.entries, want)
+	}
+}
+
+// TestHistoryFile checks that entries, including multi-line ones and ones
+// holding backslashes, come back from the file unchanged.
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	entries := []string{"echo(1);", "fn f() {\n  return `a\\nb`;\n}", `echo("\\");`, "x\r\ny"}
+
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("loading a missing file: %v", err)
+	}
+	for _, entry := range entries {
+		if err := h.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(contents), "\n"); lines != len(entries) {
+		t.Errorf("file holds %d lines for %d entries: %q", lines, len(entries), contents)
+	}
+
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(loaded.entries, entries) {
+		t.Errorf("got %q, want %q", loaded.entries, entries)
+	}
+}
+
+// TestHistoryLongEntry checks that an entry longer than a line scanner's
+// buffer, like a pasted script, is saved and loaded whole.
+func TestHistoryLongEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	long := strings.Repeat("echo(\"a long line\");\n", 20000)
+
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"echo(1);", long, "echo(2);"} {
+		if err := h.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("loading a long entry: %v", err)
+	}
+	if !slices.Equal(loaded.entries, []string{"echo(1);", long, "echo(2);"}) {
+		t.Errorf("loaded %d entries, not the 3 saved", len(loaded.entries))
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	var contents strings.Builder
+	for i := range MaxHistory + 10 {
+		contents.WriteString(strings.Repeat("x", i+1) + "\n")
+	}
+	if err := os.WriteFile(path, []byte(contents.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.entries) != MaxHistory || h.entries[0] != strings.Repeat("x", 11) {
+		t.Fatalf("kept %d entries starting with %q", len(h.entries), h.entries[0])
+	}
+
+	// The file is trimmed as well ---
+	reloaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reloaded.entries, h.entries) {
+		t.Errorf("trimmed file holds %d entries", len(reloaded.entries))
+	}
+
+	if err := h.Add("new"); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.entries) != MaxHistory || h.entries[len(h.entries)-1] != "new" {
+		t.Errorf("adding kept %d entries", len(h.entries))
+	}
+}
+
+func TestHistoryAddError(t *testing.T) {
+	h := &History{file: filepath.Join(t.TempDir(), "missing", "history")}
+	if err := h.Add("echo(1);"); err == nil {
+		t.Error("saving to a missing directory did not fail")
+	}
+}
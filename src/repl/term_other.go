@@ -0,0 +1,18 @@
+//go:build !linux
+
+package repl
+
+import (
+	"errors"
+	"os"
+)
+
+// Raw mode is only supported on Linux; elsewhere the REPL reads plain lines.
+
+func isTerminal(file *os.File) bool {
+	return false
+}
+
+func makeRaw(file *os.File) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
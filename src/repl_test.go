@@ -0,0 +1,117 @@
+package mutex
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caelondev/mutex/src/repl"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		source     string
+		incomplete bool
+	}{
+		{`echo(1);`, false},
+		{`fn f() {`, true},
+		{"fn f() {\n  return [1,", true},
+		{"fn f() {\n  return [1, 2];\n}", false},
+		{`echo((1 + 2)`, true},
+		{`var imm m = {a: [1, {b: 2}]`, true},
+		{`}`, false},
+		{`echo("{");`, false},
+		{`echo('(');`, false},
+		{`var imm s = "unclosed (`, false},
+		{"echo(\"unclosed\n", true},
+		{"var imm s = `multi", true},
+		{"var imm s = `multi\nline`;", false},
+		{"var imm s = `{`;", false},
+		{`// a comment {`, false},
+		{"/* block", true},
+		{"/* block\n { */ echo(1);", false},
+		{"echo(1); /", false},
+	}
+
+	for _, test := range tests {
+		if got := incomplete(test.source); got != test.incomplete {
+			t.Errorf("incomplete(%q) = %v, want %v", test.source, got, test.incomplete)
+		}
+	}
+}
+
+func TestReadEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		entry   string
+		prompts []string
+		err     error
+	}{
+		{name: "single line", lines: []string{"echo(1);"}, entry: "echo(1);", prompts: []string{">> "}},
+		{name: "multi-line", lines: []string{"fn f() {", "  return 1;", "}"}, entry: "fn f() {\n  return 1;\n}", prompts: []string{">> ", ".. ", ".. "}},
+		{name: "input ends inside an entry", lines: []string{"fn f() {", "  return 1;"}, entry: "fn f() {\n  return 1;", prompts: []string{">> ", ".. ", ".. "}},
+		{name: "no input", prompts: []string{">> "}, err: io.EOF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var prompts []string
+			lines := test.lines
+			readLine := func(prompt string) (string, error) {
+				prompts = append(prompts, prompt)
+				if len(lines) == 0 {
+					return "", io.EOF
+				}
+				line := lines[0]
+				lines = lines[1:]
+				return line, nil
+			}
+
+			entry, err := readEntry(readLine)
+			if err != test.err || entry != test.entry {
+				t.Errorf("got %q, %v, want %q, %v", entry, err, test.entry, test.err)
+			}
+			if len(prompts) != len(test.prompts) {
+				t.Errorf("prompted %q, want %q", prompts, test.prompts)
+			}
+		})
+	}
+}
+
+// TestHistoryPath checks that $MUTEX_HISTORY names the history file and that
+// a multi-line entry saved to it is recalled whole.
+func TestHistoryPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	t.Setenv("MUTEX_HISTORY", path)
+
+	if got := historyPath(); got != path {
+		t.Fatalf("historyPath() = %q, want %q", got, path)
+	}
+
+	history, err := repl.LoadHistory(historyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := history.Add("fn f() {\n  return 1;\n}"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := repl.LoadHistory(historyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Up recalls the newest entry ---
+	editor := repl.NewEditor(bufio.NewReader(strings.NewReader("\x1b[A\r")), io.Discard)
+	editor.History = reloaded
+	line, err := editor.ReadLine(">> ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "fn f() {\n  return 1;\n}" {
+		t.Errorf("recalled %q", line)
+	}
+}
@@ -2,9 +2,10 @@ package runtime
 
 import (
 	"fmt"
-	"slices"
+	"math"
 
 	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/frontend/ast"
 )
 
 type Environment interface {
@@ -14,30 +15,62 @@ type Environment interface {
 	ResolveVariable(variableName string) Environment
 	GetVariable(variableName string) RuntimeValue
 	LookupVariable(variableName string) RuntimeValue
+	Module() string
+	GetSlot(depth int, slot int) RuntimeValue
+	SetSlot(depth int, slot int, value RuntimeValue)
+	Interpreter() *Interpreter
 }
 
+// EnvironmentStruct holds the variables of one scope. The global scope keeps
+// them by name, so natives and earlier REPL input can be found; every other
+// scope keeps them in the slots the resolver assigned.
 type EnvironmentStruct struct {
-	parent            Environment
-	variables         map[string]RuntimeValue
-	constantVariables []string
+	parent      Environment
+	variables   map[string]RuntimeValue
+	constants   map[string]bool
+	natives     map[string]bool // Globals the interpreter declared that a program may declare again
+	slots       []RuntimeValue // nil until the variable's declaration runs
+	interpreter *Interpreter
+	module      string // Only set on global environments
 }
 
 func (e *EnvironmentStruct) Environment() {}
 
 func NewEnvironment(parentEnv Environment) *EnvironmentStruct {
-	variables := map[string]RuntimeValue{}
+	return newScope(parentEnv, 0)
+}
+
+// NewModuleEnvironment creates the global environment of the module read from
+// path. It shares the interpreter state of importer.
+func NewModuleEnvironment(importer Environment, path string) *EnvironmentStruct {
+	env := &EnvironmentStruct{
+		interpreter: importer.Interpreter(),
+		module:      path,
+	}
+	declareGlobalVariables(env)
+	return env
+}
+
+// newScope creates an environment with room for slots local variables.
+func newScope(parentEnv Environment, slots int) *EnvironmentStruct {
 	env := &EnvironmentStruct{
-		variables: variables,
-		parent:    parentEnv,
+		parent: parentEnv,
+		slots:  make([]RuntimeValue, slots),
 	}
 
 	if parentEnv == nil { // This means this is the global environment
+		env.interpreter = newInterpreter()
 		declareGlobalVariables(env)
+	} else {
+		env.interpreter = parentEnv.Interpreter()
 	}
 	return env
 }
 
-func declareGlobalVariables(env Environment) {
+// declareGlobalVariables declares the values every global environment starts
+// with. Apart from nil, true and false, a program may declare its own
+// variable in place of any of them, such as a function called map.
+func declareGlobalVariables(env *EnvironmentStruct) {
 	env.DeclareVariable("nil", NIL(), true)
 	env.DeclareVariable("true", BOOLEAN(true), true)
 	env.DeclareVariable("false", BOOLEAN(false), true)
@@ -45,25 +78,106 @@ func declareGlobalVariables(env Environment) {
 	// Native functions ---
 	env.DeclareVariable("echo", NATIVE_FUNCTION("echo", NATIVE_ECHO_FUNCTION), true)
 	env.DeclareVariable("typeof", NATIVE_FUNCTION("typeof", NATIVE_TYPEOF_FUNCTION), true)
+	env.DeclareVariable("error", NATIVE_FUNCTION("error", NATIVE_ERROR_FUNCTION), true)
 	env.DeclareVariable("push", NATIVE_FUNCTION("push", NATIVE_PUSH_FUNCTION), true)
 	env.DeclareVariable("pop", NATIVE_FUNCTION("pop", NATIVE_POP_FUNCTION), true)
 	env.DeclareVariable("shift", NATIVE_FUNCTION("shift", NATIVE_SHIFT_FUNCTION), true)
 	env.DeclareVariable("unshift", NATIVE_FUNCTION("unshift", NATIVE_UNSHIFT_FUNCTION), true)
 
+	env.DeclareVariable("keys", NATIVE_FUNCTION("keys", NATIVE_KEYS_FUNCTION), true)
+	env.DeclareVariable("values", NATIVE_FUNCTION("values", NATIVE_VALUES_FUNCTION), true)
+	env.DeclareVariable("has", NATIVE_FUNCTION("has", NATIVE_HAS_FUNCTION), true)
+	env.DeclareVariable("delete", NATIVE_FUNCTION("delete", NATIVE_DELETE_FUNCTION), true)
+
 	env.DeclareVariable("string", NATIVE_FUNCTION("string", NATIVE_STRING_FUNCTION), true)
 	env.DeclareVariable("int", NATIVE_FUNCTION("int", NATIVE_INT_FUNCTION), true)
 	env.DeclareVariable("float", NATIVE_FUNCTION("float", NATIVE_FLOAT_FUNCTION), true)
 	env.DeclareVariable("bool", NATIVE_FUNCTION("bool", NATIVE_BOOL_FUNCTION), true)
 
+	env.DeclareVariable("len", NATIVE_FUNCTION("len", NATIVE_LEN_FUNCTION), true)
+	env.DeclareVariable("substr", NATIVE_FUNCTION("substr", NATIVE_SUBSTR_FUNCTION), true)
+	env.DeclareVariable("index_of", NATIVE_FUNCTION("index_of", NATIVE_INDEX_OF_FUNCTION), true)
+	env.DeclareVariable("split", NATIVE_FUNCTION("split", NATIVE_SPLIT_FUNCTION), true)
+	env.DeclareVariable("join", NATIVE_FUNCTION("join", NATIVE_JOIN_FUNCTION), true)
+	env.DeclareVariable("replace", NATIVE_FUNCTION("replace", NATIVE_REPLACE_FUNCTION), true)
+	env.DeclareVariable("trim", NATIVE_FUNCTION("trim", NATIVE_TRIM_FUNCTION), true)
+	env.DeclareVariable("upper", NATIVE_FUNCTION("upper", NATIVE_UPPER_FUNCTION), true)
+	env.DeclareVariable("lower", NATIVE_FUNCTION("lower", NATIVE_LOWER_FUNCTION), true)
+	env.DeclareVariable("starts_with", NATIVE_FUNCTION("starts_with", NATIVE_STARTS_WITH_FUNCTION), true)
+	env.DeclareVariable("ends_with", NATIVE_FUNCTION("ends_with", NATIVE_ENDS_WITH_FUNCTION), true)
+	env.DeclareVariable("repeat", NATIVE_FUNCTION("repeat", NATIVE_REPEAT_FUNCTION), true)
+	env.DeclareVariable("chars", NATIVE_FUNCTION("chars", NATIVE_CHARS_FUNCTION), true)
+
+	env.DeclareVariable("read_file", NATIVE_FUNCTION("read_file", NATIVE_READ_FILE_FUNCTION), true)
+	env.DeclareVariable("write_file", NATIVE_FUNCTION("write_file", NATIVE_WRITE_FILE_FUNCTION), true)
+	env.DeclareVariable("append_file", NATIVE_FUNCTION("append_file", NATIVE_APPEND_FILE_FUNCTION), true)
+	env.DeclareVariable("exists", NATIVE_FUNCTION("exists", NATIVE_EXISTS_FUNCTION), true)
+	env.DeclareVariable("list_dir", NATIVE_FUNCTION("list_dir", NATIVE_LIST_DIR_FUNCTION), true)
+	env.DeclareVariable("read_line", NATIVE_FUNCTION("read_line", NATIVE_READ_LINE_FUNCTION), true)
+
+	env.DeclareVariable("map", NATIVE_FUNCTION("map", NATIVE_MAP_FUNCTION), true)
+	env.DeclareVariable("filter", NATIVE_FUNCTION("filter", NATIVE_FILTER_FUNCTION), true)
+	env.DeclareVariable("reduce", NATIVE_FUNCTION("reduce", NATIVE_REDUCE_FUNCTION), true)
+	env.DeclareVariable("find", NATIVE_FUNCTION("find", NATIVE_FIND_FUNCTION), true)
+	env.DeclareVariable("any", NATIVE_FUNCTION("any", NATIVE_ANY_FUNCTION), true)
+	env.DeclareVariable("all", NATIVE_FUNCTION("all", NATIVE_ALL_FUNCTION), true)
+	env.DeclareVariable("sort", NATIVE_FUNCTION("sort", NATIVE_SORT_FUNCTION), true)
+	env.DeclareVariable("reverse", NATIVE_FUNCTION("reverse", NATIVE_REVERSE_FUNCTION), true)
+	env.DeclareVariable("slice", NATIVE_FUNCTION("slice", NATIVE_SLICE_FUNCTION), true)
+	env.DeclareVariable("concat", NATIVE_FUNCTION("concat", NATIVE_CONCAT_FUNCTION), true)
+	env.DeclareVariable("contains", NATIVE_FUNCTION("contains", NATIVE_CONTAINS_FUNCTION), true)
+	env.DeclareVariable("range", NATIVE_FUNCTION("range", NATIVE_RANGE_FUNCTION), true)
+
+	env.DeclareVariable("pi", NUMBER(math.Pi), true)
+	env.DeclareVariable("e", NUMBER(math.E), true)
+	env.DeclareVariable("abs", NATIVE_FUNCTION("abs", NATIVE_ABS_FUNCTION), true)
+	env.DeclareVariable("floor", NATIVE_FUNCTION("floor", NATIVE_FLOOR_FUNCTION), true)
+	env.DeclareVariable("ceil", NATIVE_FUNCTION("ceil", NATIVE_CEIL_FUNCTION), true)
+	env.DeclareVariable("round", NATIVE_FUNCTION("round", NATIVE_ROUND_FUNCTION), true)
+	env.DeclareVariable("sqrt", NATIVE_FUNCTION("sqrt", NATIVE_SQRT_FUNCTION), true)
+	env.DeclareVariable("pow", NATIVE_FUNCTION("pow", NATIVE_POW_FUNCTION), true)
+	env.DeclareVariable("min", NATIVE_FUNCTION("min", NATIVE_MIN_FUNCTION), true)
+	env.DeclareVariable("max", NATIVE_FUNCTION("max", NATIVE_MAX_FUNCTION), true)
+	env.DeclareVariable("log", NATIVE_FUNCTION("log", NATIVE_LOG_FUNCTION), true)
+	env.DeclareVariable("sin", NATIVE_FUNCTION("sin", NATIVE_SIN_FUNCTION), true)
+	env.DeclareVariable("cos", NATIVE_FUNCTION("cos", NATIVE_COS_FUNCTION), true)
+	env.DeclareVariable("tan", NATIVE_FUNCTION("tan", NATIVE_TAN_FUNCTION), true)
+	env.DeclareVariable("asin", NATIVE_FUNCTION("asin", NATIVE_ASIN_FUNCTION), true)
+	env.DeclareVariable("acos", NATIVE_FUNCTION("acos", NATIVE_ACOS_FUNCTION), true)
+	env.DeclareVariable("atan", NATIVE_FUNCTION("atan", NATIVE_ATAN_FUNCTION), true)
+	env.DeclareVariable("atan2", NATIVE_FUNCTION("atan2", NATIVE_ATAN2_FUNCTION), true)
+	env.DeclareVariable("div", NATIVE_FUNCTION("div", NATIVE_DIV_FUNCTION), true)
+	env.DeclareVariable("random", NATIVE_FUNCTION("random", NATIVE_RANDOM_FUNCTION), true)
+	env.DeclareVariable("random_int", NATIVE_FUNCTION("random_int", NATIVE_RANDOM_INT_FUNCTION), true)
+	env.DeclareVariable("seed", NATIVE_FUNCTION("seed", NATIVE_SEED_FUNCTION), true)
+
+	env.DeclareVariable("json_parse", NATIVE_FUNCTION("json_parse", NATIVE_JSON_PARSE_FUNCTION), true)
+	env.DeclareVariable("json_stringify", NATIVE_FUNCTION("json_stringify", NATIVE_JSON_STRINGIFY_FUNCTION), true)
+
+	env.natives = map[string]bool{}
+	for name := range env.variables {
+		env.natives[name] = name != "nil" && name != "true" && name != "false"
+	}
 }
 
 func (e *EnvironmentStruct) DeclareVariable(variableName string, value RuntimeValue, isConstant bool) RuntimeValue {
 	if _, exists := e.variables[variableName]; exists {
-		errors.ReportInterpreter(fmt.Sprintf("Cannot declare variable \"%s\" as it is already defined", variableName), 65)
+		if !e.natives[variableName] {
+			errors.RaiseRuntime(nil, fmt.Sprintf("Cannot declare variable \"%s\" as it is already defined", variableName))
+		}
+
+		// The program's own declaration takes the native's place ---
+		delete(e.natives, variableName)
+		delete(e.constants, variableName)
+	}
+
+	if e.variables == nil {
+		e.variables = map[string]RuntimeValue{}
+		e.constants = map[string]bool{}
 	}
 
 	if isConstant {
-		e.constantVariables = append(e.constantVariables, variableName)
+		e.constants[variableName] = true
 	}
 
 	e.variables[variableName] = value
@@ -74,15 +188,15 @@ func (e *EnvironmentStruct) AssignVariable(variableName string, value RuntimeVal
 	env := e.ResolveVariable(variableName)
 
 	if envStruct, ok := env.(*EnvironmentStruct); ok {
-		if slices.Contains(envStruct.constantVariables, variableName) {
-			errors.ReportInterpreter(fmt.Sprintf("Cannot re-assign constant variable \"%s\"", variableName), 65)
+		if envStruct.constants[variableName] {
+			errors.RaiseRuntime(nil, fmt.Sprintf("Cannot re-assign constant variable \"%s\"", variableName))
 		}
 		
 		envStruct.variables[variableName] = value
 		return NIL()
 	}
 
-	errors.ReportInterpreter(fmt.Sprintf("Cannot re-assign variable \"%s\" as it does not exist in the current scope", variableName), 65)
+	errors.RaiseRuntime(nil, fmt.Sprintf("Cannot re-assign variable \"%s\" as it does not exist in the current scope", variableName))
 	return NIL()
 }
 
@@ -92,12 +206,16 @@ func (e *EnvironmentStruct) ResolveVariable(variableName string) Environment {
 	}
 
 	if e.parent == nil {
-		errors.ReportInterpreter(fmt.Sprintf("Cannot resolve variable \"%s\" as it does not exist in the current/outer scopes", variableName), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot resolve variable \"%s\" as it does not exist in the current/outer scopes", variableName))
 	}
 
 	return e.parent.ResolveVariable(variableName)
 }
 
+func (e *EnvironmentStruct) Interpreter() *Interpreter {
+	return e.interpreter
+}
+
 func (e *EnvironmentStruct) GetVariable(variableName string) RuntimeValue {
 	return e.variables[variableName]
 }
@@ -106,3 +224,87 @@ func (e *EnvironmentStruct) LookupVariable(variableName string) RuntimeValue {
 	env := e.ResolveVariable(variableName)
 	return env.GetVariable(variableName)
 }
+
+// Module returns the path of the file the environment's code was read from,
+// or an empty string when it was not read from a file.
+func (e *EnvironmentStruct) Module() string {
+	if e.parent != nil {
+		return e.parent.Module()
+	}
+	return e.module
+}
+
+// SetModule records path as the file the global environment's code is read
+// from, which is where its relative imports are looked up.
+func (e *EnvironmentStruct) SetModule(path string) {
+	e.module = path
+}
+
+// IsDeclared reports whether this scope itself declares variableName.
+func (e *EnvironmentStruct) IsDeclared(variableName string) bool {
+	_, exists := e.variables[variableName]
+	return exists
+}
+
+// IsNative reports whether variableName is a global the interpreter declared
+// and the program has not declared again, which the program may still do.
+func (e *EnvironmentStruct) IsNative(variableName string) bool {
+	return e.natives[variableName]
+}
+
+// IsConstant reports whether this scope declares variableName as constant.
+func (e *EnvironmentStruct) IsConstant(variableName string) bool {
+	return e.constants[variableName]
+}
+
+func (e *EnvironmentStruct) GetSlot(depth int, slot int) RuntimeValue {
+	if depth > 0 {
+		return e.parent.GetSlot(depth-1, slot)
+	}
+	return e.slots[slot]
+}
+
+func (e *EnvironmentStruct) SetSlot(depth int, slot int, value RuntimeValue) {
+	if depth > 0 {
+		e.parent.SetSlot(depth-1, slot, value)
+		return
+	}
+	e.slots[slot] = value
+}
+
+// lookup reads the variable called name that binding points to from env.
+func lookup(env Environment, binding ast.Binding, name string) RuntimeValue {
+	if !binding.Local {
+		return env.LookupVariable(name)
+	}
+
+	value := env.GetSlot(binding.Depth, binding.Slot)
+	if value == nil {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot use variable \"%s\" before it is declared", name))
+	}
+	return value
+}
+
+// assign stores value in the variable called name that binding points to.
+// The resolver has already rejected assignments to local constants.
+func assign(env Environment, binding ast.Binding, name string, value RuntimeValue) {
+	if !binding.Local {
+		env.AssignVariable(name, value)
+		return
+	}
+
+	if env.GetSlot(binding.Depth, binding.Slot) == nil {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot use variable \"%s\" before it is declared", name))
+	}
+	env.SetSlot(binding.Depth, binding.Slot, value)
+}
+
+// declare gives the variable called name declared with binding its first value.
+func declare(env Environment, binding ast.Binding, name string, value RuntimeValue, isConstant bool) {
+	if !binding.Local {
+		env.DeclareVariable(name, value, isConstant)
+		return
+	}
+
+	env.SetSlot(binding.Depth, binding.Slot, value)
+}
@@ -3,6 +3,8 @@ package runtime
 import (
 	"fmt"
 	"math"
+	"slices"
+	"strings"
 
 	"github.com/caelondev/mutex/src/errors"
 	"github.com/caelondev/mutex/src/frontend/ast"
@@ -20,33 +22,39 @@ func evaluateStringExpression(expr *ast.StringExpression) RuntimeValue {
 func evaluateBinaryExpression(expr *ast.BinaryExpression, env Environment) RuntimeValue {
 	// Handle short-circuit evaluation for logical operators
 	if expr.Operator.TokenType == lexer.AND {
-		left := EvaluateExpression(expr.Left, env)
-		if !isTruthy(left) {
+		left := evaluateExpression(expr.Left, env)
+		if !IsTruthy(left) {
 			return BOOLEAN(false)
 		}
-		right := EvaluateExpression(expr.Right, env)
-		return BOOLEAN(isTruthy(right))
+		right := evaluateExpression(expr.Right, env)
+		return BOOLEAN(IsTruthy(right))
 	}
 
 	if expr.Operator.TokenType == lexer.OR {
-		left := EvaluateExpression(expr.Left, env)
-		if isTruthy(left) {
+		left := evaluateExpression(expr.Left, env)
+		if IsTruthy(left) {
 			return BOOLEAN(true)
 		}
-		right := EvaluateExpression(expr.Right, env)
-		return BOOLEAN(isTruthy(right))
+		right := evaluateExpression(expr.Right, env)
+		return BOOLEAN(IsTruthy(right))
 	}
 
 	// Evaluate both operands for all other operators
-	left := EvaluateExpression(expr.Left, env)
-	right := EvaluateExpression(expr.Right, env)
+	left := evaluateExpression(expr.Left, env)
+	right := evaluateExpression(expr.Right, env)
 
+	return BinaryOperation(&expr.Operator, left, right)
+}
+
+// BinaryOperation applies a non-logical binary operator to two evaluated
+// operands. Errors point at the operator token.
+func BinaryOperation(operator *lexer.Token, left RuntimeValue, right RuntimeValue) RuntimeValue {
 	// Handle string operations
 	leftStr, leftIsStr := left.(*StringValue)
 	rightStr, rightIsStr := right.(*StringValue)
 
 	if leftIsStr && rightIsStr {
-		return evaluateStringBinaryExpression(leftStr, rightStr, expr.Operator)
+		return evaluateStringBinaryExpression(leftStr, rightStr, operator)
 	}
 
 	// Handle numeric operations
@@ -54,33 +62,55 @@ func evaluateBinaryExpression(expr *ast.BinaryExpression, env Environment) Runti
 	rightNum, rightIsNum := right.(*NumberValue)
 
 	if leftIsNum && rightIsNum {
-		return evaluateNumericBinaryExpression(leftNum, rightNum, expr.Operator)
+		return evaluateNumericBinaryExpression(leftNum, rightNum, operator)
+	}
+
+	// Any two values can be compared; arrays, maps, functions and instances
+	// are only equal to themselves ---
+	switch operator.TokenType {
+	case lexer.EQUAL_TO:
+		return BOOLEAN(valuesEqual(left, right))
+	case lexer.NOT_EQUAL:
+		return BOOLEAN(!valuesEqual(left, right))
 	}
 
 	// Type mismatch
-	errors.ReportInterpreter(fmt.Sprintf("Cannot perform operation %s on incompatible types", expr.Operator.Lexeme), 65)
+	errors.RaiseRuntime(operator, fmt.Sprintf("Cannot perform operation %s on incompatible types", operator.Lexeme))
 	return NIL()
 }
 
-func evaluateStringBinaryExpression(left *StringValue, right *StringValue, operator lexer.Token) RuntimeValue {
+func evaluateStringBinaryExpression(left *StringValue, right *StringValue, operator *lexer.Token) RuntimeValue {
 	lhs := left.Value
 	rhs := right.Value
 
 	switch operator.TokenType {
 	case lexer.PLUS:
+		if len(lhs)+len(rhs) > MaxAllocation {
+			errors.RaiseLimit(fmt.Sprintf("+ would build a string longer than %d bytes", MaxAllocation), nil)
+		}
 		return &StringValue{Value: lhs + rhs}
 	case lexer.EQUAL_TO:
 		return BOOLEAN(lhs == rhs)
 	case lexer.NOT_EQUAL:
 		return BOOLEAN(lhs != rhs)
+
+	// Ordered by code point, character by character ---
+	case lexer.LESS:
+		return BOOLEAN(lhs < rhs)
+	case lexer.LESS_EQUAL:
+		return BOOLEAN(lhs <= rhs)
+	case lexer.GREATER:
+		return BOOLEAN(lhs > rhs)
+	case lexer.GREATER_EQUAL:
+		return BOOLEAN(lhs >= rhs)
 	default:
-		errors.ReportInterpreter(fmt.Sprintf("Unsupported string operator: %s", operator.Lexeme), 65)
+		errors.RaiseRuntime(operator, fmt.Sprintf("Unsupported string operator: %s", operator.Lexeme))
 	}
 
 	return NIL()
 }
 
-func evaluateNumericBinaryExpression(left *NumberValue, right *NumberValue, operator lexer.Token) RuntimeValue {
+func evaluateNumericBinaryExpression(left *NumberValue, right *NumberValue, operator *lexer.Token) RuntimeValue {
 	result := 0.0
 	lhs := left.Value
 	rhs := right.Value
@@ -94,12 +124,12 @@ func evaluateNumericBinaryExpression(left *NumberValue, right *NumberValue, oper
 		result = lhs * rhs
 	case lexer.SLASH:
 		if rhs == 0 {
-			errors.ReportInterpreter("Division by zero", 65)
+			errors.RaiseRuntime(operator, "Division by zero")
 		}
 		result = lhs / rhs
 	case lexer.MODULO:
 		if rhs == 0 {
-			errors.ReportInterpreter("Modulo by zero", 65)
+			errors.RaiseRuntime(operator, "Modulo by zero")
 		}
 		result = math.Mod(lhs, rhs)
 	case lexer.LESS:
@@ -115,39 +145,49 @@ func evaluateNumericBinaryExpression(left *NumberValue, right *NumberValue, oper
 	case lexer.NOT_EQUAL:
 		return BOOLEAN(lhs != rhs)
 	default:
-		errors.ReportInterpreter(fmt.Sprintf("Unsupported binary operator: %s", operator.Lexeme), 65)
+		errors.RaiseRuntime(operator, fmt.Sprintf("Unsupported binary operator: %s", operator.Lexeme))
 	}
 
 	return &NumberValue{Value: result}
 }
 
 func evaluateSymbolExpression(expr *ast.SymbolExpression, env Environment) RuntimeValue {
-	return env.LookupVariable(expr.Value)
+	return lookup(env, expr.Binding, expr.Value)
 }
 
 func evaluateAssignmentExpression(expr *ast.AssignmentExpression, env Environment) RuntimeValue {
-	symbol := expr.Assignee.(*ast.SymbolExpression)
-	value := EvaluateExpression(expr.NewValue, env)
+	symbol, ok := expr.Assignee.(*ast.SymbolExpression)
+	if !ok {
+		errors.RaiseRuntime(nil, "Invalid assignment target, expected a variable")
+	}
+
+	value := evaluateExpression(expr.NewValue, env)
 
-	return env.AssignVariable(symbol.Value, value)
+	assign(env, symbol.Binding, symbol.Value, value)
+	return NIL()
 }
 
 func evaluateUnaryExpression(expr *ast.UnaryExpression, env Environment) RuntimeValue {
-	operand := EvaluateExpression(expr.Operand, env)
+	operand := evaluateExpression(expr.Operand, env)
+
+	return UnaryOperation(&expr.Operator, operand)
+}
 
-	switch expr.Operator.TokenType {
+// UnaryOperation applies a prefix operator to an evaluated operand.
+func UnaryOperation(operator *lexer.Token, operand RuntimeValue) RuntimeValue {
+	switch operator.TokenType {
 	case lexer.NOT:
-		return BOOLEAN(!isTruthy(operand))
+		return BOOLEAN(!IsTruthy(operand))
 
 	case lexer.MINUS:
 		numValue, ok := operand.(*NumberValue)
 		if !ok {
-			errors.ReportInterpreter("Unary minus requires numeric operand", 65)
+			errors.RaiseRuntime(operator, "Unary minus requires numeric operand")
 		}
 		return &NumberValue{Value: -numValue.Value}
 
 	default:
-		errors.ReportInterpreter(fmt.Sprintf("Unknown unary operator: %s", expr.Operator.Lexeme), 65)
+		errors.RaiseRuntime(operator, fmt.Sprintf("Unknown unary operator: %s", operator.Lexeme))
 	}
 
 	return NIL()
@@ -156,142 +196,327 @@ func evaluateUnaryExpression(expr *ast.UnaryExpression, env Environment) Runtime
 func evaluatePostfixExpression(expr *ast.PostfixExpression, env Environment) RuntimeValue {
 	symbol, ok := expr.Operand.(*ast.SymbolExpression)
 	if !ok {
-		errors.ReportInterpreter("Postfix operators can only be applied to variables", 65)
+		errors.RaiseRuntime(&expr.Operator, "Postfix operators can only be applied to variables")
 	}
 
-	currentValue := env.LookupVariable(symbol.Value)
-	numValue, ok := currentValue.(*NumberValue)
+	currentValue := lookup(env, symbol.Binding, symbol.Value)
+
+	assign(env, symbol.Binding, symbol.Value, PostfixOperation(&expr.Operator, currentValue))
+	return currentValue
+}
+
+// PostfixOperation returns the value a variable holding value is updated to
+// by the postfix operator ++ or --.
+func PostfixOperation(operator *lexer.Token, value RuntimeValue) RuntimeValue {
+	numValue, ok := value.(*NumberValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("Postfix operator %s requires numeric operand", expr.Operator.Lexeme), 65)
+		errors.RaiseRuntime(operator, fmt.Sprintf("Postfix operator %s requires numeric operand", operator.Lexeme))
 	}
 
-	var newValue *NumberValue
-	switch expr.Operator.TokenType {
+	switch operator.TokenType {
 	case lexer.PLUS_PLUS:
-		newValue = &NumberValue{Value: numValue.Value + 1}
+		return &NumberValue{Value: numValue.Value + 1}
 	case lexer.MINUS_MINUS:
-		newValue = &NumberValue{Value: numValue.Value - 1}
+		return &NumberValue{Value: numValue.Value - 1}
 	default:
-		errors.ReportInterpreter(fmt.Sprintf("Unknown postfix operator: %s", expr.Operator.Lexeme), 65)
+		errors.RaiseRuntime(operator, fmt.Sprintf("Unknown postfix operator: %s", operator.Lexeme))
 	}
 
-	env.AssignVariable(symbol.Value, newValue)
-	return numValue
+	return NIL()
 }
 
 func evaluateArrayExpression(expr *ast.ArrayExpression, env Environment) RuntimeValue {
 	elements := make([]RuntimeValue, len(expr.Elements))
 	
 	for i, elemExpr := range expr.Elements {
-		elements[i] = EvaluateExpression(elemExpr, env)
+		elements[i] = evaluateExpression(elemExpr, env)
 	}
 	
 	return ARRAY(elements)
 }
 
 func evaluateIndexExpression(expr *ast.ArrayIndexExpression, env Environment) RuntimeValue {
-	object := EvaluateExpression(expr.Object, env)
-	index := EvaluateExpression(expr.Index, env)
-	
+	object := evaluateExpression(expr.Object, env)
+	index := evaluateExpression(expr.Index, env)
+
+	return GetIndex(object, index)
+}
+
+// GetIndex evaluates object[index] for arrays and maps.
+func GetIndex(object RuntimeValue, index RuntimeValue) RuntimeValue {
+	// Maps are indexed by key ---
+	if mapValue, ok := object.(*MapValue); ok {
+		value, exists := mapValue.Get(mapKey(index))
+		if !exists {
+			return NIL()
+		}
+		return value
+	}
+
+	// Strings are indexed by character, not byte ---
+	if str, ok := object.(*StringValue); ok {
+		runes := []rune(str.Value)
+		idx := checkIndex("String", index, len(runes))
+		return &StringValue{Value: string(runes[idx])}
+	}
+
+	arrayValue, idx := arrayIndex(object, index)
+
+	return arrayValue.Elements[idx]
+}
+
+func evaluateIndexAssignmentExpression(expr *ast.ArrayIndexAssignmentExpression, env Environment) RuntimeValue {
+	object := evaluateExpression(expr.Object, env)
+	index := evaluateExpression(expr.Index, env)
+	newValue := evaluateExpression(expr.NewValue, env)
+
+	SetIndex(object, index, newValue)
+	return NIL()
+}
+
+// SetIndex performs object[index] = newValue for arrays and maps.
+func SetIndex(object RuntimeValue, index RuntimeValue, newValue RuntimeValue) {
+	// Maps gain the key if it is not there yet ---
+	if mapValue, ok := object.(*MapValue); ok {
+		mapValue.Set(mapKey(index), newValue)
+		return
+	}
+
+	if _, ok := object.(*StringValue); ok {
+		errors.RaiseRuntime(nil, "Cannot assign to a string index, strings are immutable")
+	}
+
+	arrayValue, idx := arrayIndex(object, index)
+
+	// Mutate the array in place
+	arrayValue.Elements[idx] = newValue
+}
+
+// arrayIndex checks that object is an array and index a number within its
+// bounds, and returns both in their concrete types.
+func arrayIndex(object RuntimeValue, index RuntimeValue) (*ArrayValue, int) {
 	// Check if object is an array
 	arrayValue, ok := object.(*ArrayValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("Cannot index into type '%s', expected array", object.Type()), 65)
-		return NIL()
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot index into type '%s', expected array, map or string", object.Type()))
 	}
-	
+
+	return arrayValue, checkIndex("Array", index, len(arrayValue.Elements))
+}
+
+// checkIndex checks that index is a number within the bounds of a kind value
+// of the given length, and returns it as an integer.
+func checkIndex(kind string, index RuntimeValue, length int) int {
 	// Check if index is a number
 	indexNum, ok := index.(*NumberValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("Array index must be a number, got '%s'", index.Type()), 65)
-		return NIL()
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s index must be a number, got '%s'", kind, index.Type()))
 	}
-	
+
 	// Convert to integer and check bounds
 	idx := int(indexNum.Value)
-	
-	if idx < 0 || idx >= len(arrayValue.Elements) {
-		errors.ReportInterpreter(fmt.Sprintf("Array index %d out of bounds (array length: %d)", idx, len(arrayValue.Elements)), 65)
-		return NIL()
+
+	if idx < 0 || idx >= length {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s index %d out of bounds (%s length: %d)", kind, idx, strings.ToLower(kind), length))
 	}
-	
-	return arrayValue.Elements[idx]
+
+	return idx
 }
 
-func evaluateIndexAssignmentExpression(expr *ast.ArrayIndexAssignmentExpression, env Environment) RuntimeValue {
-	object := EvaluateExpression(expr.Object, env)
-	index := EvaluateExpression(expr.Index, env)
-	newValue := EvaluateExpression(expr.NewValue, env)
-	
-	// Check if object is an array
-	arrayValue, ok := object.(*ArrayValue)
+func mapKey(index RuntimeValue) string {
+	key, ok := index.(*StringValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("Cannot index into type '%s', expected array", object.Type()), 65)
-		return NIL()
+		errors.RaiseRuntime(nil, fmt.Sprintf("Map key must be a string, got '%s'", index.Type()))
 	}
-	
-	// Check if index is a number
-	indexNum, ok := index.(*NumberValue)
+
+	return key.Value
+}
+
+func evaluateMapExpression(expr *ast.MapExpression, env Environment) RuntimeValue {
+	mapValue := MAP()
+
+	for _, entry := range expr.Entries {
+		mapValue.Set(entry.Key, evaluateExpression(entry.Value, env))
+	}
+
+	return mapValue
+}
+
+func evaluateMemberExpression(expr *ast.MemberExpression, env Environment) RuntimeValue {
+	object := evaluateExpression(expr.Object, env)
+
+	return GetMember(object, expr.Property)
+}
+
+// GetMember evaluates object.name: an instance field or bound method, a map
+// entry, or a property or method of a built-in type.
+func GetMember(object RuntimeValue, name string) RuntimeValue {
+	// Fields shadow methods ---
+	if instance, ok := object.(*InstanceValue); ok {
+		if value, exists := instance.Fields.Get(name); exists {
+			return value
+		}
+
+		if method, exists := instance.Class.FindMethod(name); exists {
+			return method.Bind(instance)
+		}
+
+		errors.RaiseRuntime(nil, fmt.Sprintf("Instance of '%s' has no property '%s'", instance.Class.Name, name))
+	}
+
+	if module, ok := object.(*ModuleValue); ok {
+		if !slices.Contains(module.Exports, name) {
+			errors.RaiseRuntime(nil, fmt.Sprintf("Module '%s' has no export '%s'", module.Name, name))
+		}
+		return module.env.LookupVariable(name)
+	}
+
+	mapValue, ok := object.(*MapValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("Array index must be a number, got '%s'", index.Type()), 65)
-		return NIL()
+		return builtinMember(object, name)
 	}
-	
-	// Convert to integer and check bounds
-	idx := int(indexNum.Value)
-	
-	if idx < 0 || idx >= len(arrayValue.Elements) {
-		errors.ReportInterpreter(fmt.Sprintf("Array index %d out of bounds (array length: %d)", idx, len(arrayValue.Elements)), 65)
+
+	value, exists := mapValue.Get(name)
+	if !exists {
 		return NIL()
 	}
-	
-	// Mutate the array in place
-	arrayValue.Elements[idx] = newValue
-	
+	return value
+}
+
+func evaluateMemberAssignmentExpression(expr *ast.MemberAssignmentExpression, env Environment) RuntimeValue {
+	object := evaluateExpression(expr.Object, env)
+	newValue := evaluateExpression(expr.NewValue, env)
+
+	SetMember(object, expr.Property, newValue)
 	return NIL()
 }
 
+// SetMember performs object.name = newValue on instances and maps.
+func SetMember(object RuntimeValue, name string, newValue RuntimeValue) {
+	if instance, ok := object.(*InstanceValue); ok {
+		instance.Fields.Set(name, newValue)
+		return
+	}
+
+	if module, ok := object.(*ModuleValue); ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot assign to '%s' of module '%s' from outside the module", name, module.Name))
+	}
+
+	mapValue, ok := object.(*MapValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot set property '%s' on type '%s'", name, object.Type()))
+	}
+
+	mapValue.Set(name, newValue)
+}
+
 func evaluateCallExpression(expr *ast.CallExpression, env Environment) RuntimeValue {
-	callee := EvaluateExpression(expr.Callee, env) // Parse identifier ---
+	callee := evaluateExpression(expr.Callee, env) // Parse identifier ---
 	
 	// Evaluate all arguments ---
 	var args []RuntimeValue
 	for _, argExpr := range expr.Arguments {
-		args = append(args, EvaluateExpression(argExpr, env))
+		args = append(args, evaluateExpression(argExpr, env))
 	}
 	
-	// Check if is a native function ---
-	if nativeFunc, ok := callee.(*NativeFunctionValue); ok {
-		return nativeFunc.Call(args, env)
+	return CallValue(callee, args, env)
+}
+
+// CallValue calls callee with already evaluated arguments. Natives run in env.
+// Natives that take a function argument call back into it through CallValue,
+// which works for functions of either backend.
+func CallValue(callee RuntimeValue, args []RuntimeValue, env Environment) RuntimeValue {
+	switch function := callee.(type) {
+	case *NativeFunctionValue:
+		return callNative(function, args, env)
+	case *FunctionValue:
+		return callFunction(function, args)
+	case Callable:
+		return function.Invoke(args)
+	case *ClassValue:
+		return instantiateClass(function, args, env)
 	}
 	
-	if function, ok := callee.(*FunctionValue); ok {
-		// Check argument count ---
-		if len(args) != len(function.Parameters) {
-			errors.ReportInterpreter(
-				fmt.Sprintf("Function '%s' expects %d arguments but got %d", 
-					function.Name, len(function.Parameters), len(args)), 65)
-		}
-		
-		// Create new environment for function execution (using closure) ---
-		funcEnv := NewEnvironment(function.Closure)
-		
-		// Bind arguments to parameters ---
-		for i, param := range function.Parameters {
-			funcEnv.DeclareVariable(param, args[i], false) // params are mutable ---
-		}
-		
-		// Execute function body ---
-		result := EvaluateStatement(function.Body, funcEnv)
-		
-		// Unwrap return value if present ---
-		if returnVal, ok := result.(*ReturnValue); ok {
-			return returnVal.Value
-		}
-		
-		return NIL()
+	errors.RaiseRuntime(nil, fmt.Sprintf("Cannot call non-function value of type '%s'", callee.Type()))
+	return NIL()
+}
+
+func callNative(function *NativeFunctionValue, args []RuntimeValue, env Environment) RuntimeValue {
+	defer leaveFunction(function.Name, true)
+	return function.Call(args, env)
+}
+
+func callFunction(function *FunctionValue, args []RuntimeValue) RuntimeValue {
+	// Check argument count ---
+	if len(args) != len(function.Parameters) {
+		errors.RaiseRuntime(nil, 
+			fmt.Sprintf("Function '%s' expects %d arguments but got %d", 
+				function.Name, len(function.Parameters), len(args)))
+	}
+
+	interpreter := function.Closure.Interpreter()
+	interpreter.EnterCall()
+	defer interpreter.LeaveCall()
+
+	// Counted from here, so the caller is blamed for a wrong argument count
+	// or a call nested too deeply ---
+	defer leaveFunction(function.Name, false)
+	
+	// Create new environment for function execution (using closure) ---
+	funcEnv := newScope(function.Closure, len(function.Parameters))
+	
+	// Bind arguments to parameters ---
+	copy(funcEnv.slots, args)
+	
+	// Execute function body ---
+	result := evaluateStatement(function.Body, funcEnv)
+	
+	// Unwrap return value if present ---
+	if returnVal, ok := result.(*ReturnValue); ok {
+		return returnVal.Value
 	}
 	
-	errors.ReportInterpreter(fmt.Sprintf("Cannot call non-function value of type '%s'", callee.Type()), 65)
 	return NIL()
 }
+
+func instantiateClass(class *ClassValue, args []RuntimeValue, env Environment) RuntimeValue {
+	instance := INSTANCE(class)
+
+	// Run the constructor, inherited ones included ---
+	if init, ok := class.FindMethod("init"); ok {
+		CallValue(init.Bind(instance), args, env)
+	} else if len(args) != 0 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Class '%s' has no init method and expects 0 arguments but got %d", class.Name, len(args)))
+	}
+
+	return instance
+}
+
+// bindMethod returns a copy of method whose closure has 'this' set to instance.
+func bindMethod(method *FunctionValue, instance *InstanceValue) *FunctionValue {
+	methodEnv := newScope(method.Closure, 1)
+	methodEnv.SetSlot(0, 0, instance)
+
+	return FUNCTION(method.Name, method.Parameters, method.Body, methodEnv)
+}
+
+func evaluateThisExpression(expr *ast.ThisExpression, env Environment) RuntimeValue {
+	return lookup(env, expr.Binding, "this")
+}
+
+func evaluateSuperExpression(expr *ast.SuperExpression, env Environment) RuntimeValue {
+	superclass := lookup(env, expr.Super, "super").(*ClassValue)
+	instance := lookup(env, expr.This, "this").(*InstanceValue)
+
+	return SuperMethod(superclass, instance, expr.Method)
+}
+
+// SuperMethod looks name up on superclass and binds it to instance.
+func SuperMethod(superclass *ClassValue, instance *InstanceValue, name string) RuntimeValue {
+	method, ok := superclass.FindMethod(name)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Superclass '%s' has no method '%s'", superclass.Name, name))
+	}
+
+	return method.Bind(instance)
+}
@@ -1,6 +1,13 @@
 package runtime
 
-func isTruthy(value RuntimeValue) bool {
+import (
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/frontend/lexer"
+)
+
+// IsTruthy reports whether value counts as true in a condition. nil, false,
+// 0 and the empty string are false, everything else is true.
+func IsTruthy(value RuntimeValue) bool {
 	switch v := value.(type) {
 	case *NilValue:
 		return false
@@ -14,3 +21,39 @@ func isTruthy(value RuntimeValue) bool {
 		return true
 	}
 }
+
+// locate gives a RuntimeError raised without a location the span of the node
+// being evaluated in env, and records the node in its trace. Deferred by
+// every evaluation, so the innermost node wins.
+func locate(node interface{ Span() lexer.Span }, env Environment) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if err, ok := r.(*errors.RuntimeError); ok {
+		span := node.Span()
+		if err.Span.IsZero() {
+			err.Locate(span)
+		}
+		if err.File == "" {
+			err.File = env.Module()
+		}
+		err.LocateFrame(span, env.Module())
+	}
+	panic(r)
+}
+
+// leaveFunction adds the call of the function name to the trace of a
+// RuntimeError leaving it. Deferred by every call.
+func leaveFunction(name string, native bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if err, ok := r.(*errors.RuntimeError); ok {
+		err.LeaveFunction(name, native)
+	}
+	panic(r)
+}
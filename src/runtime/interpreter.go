@@ -1,14 +1,200 @@
 package runtime
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"os"
+	goruntime "runtime"
+	"runtime/metrics"
 
+	"github.com/caelondev/mutex/src/errors"
 	"github.com/caelondev/mutex/src/frontend/ast"
-	"github.com/sanity-io/litter"
 )
 
-func EvaluateExpression(node ast.Expression, env Environment) RuntimeValue {
+// Interpreter holds the state shared by a global environment and every
+// environment nested inside it. Each global environment gets its own, so
+// independent interpreters never observe each other.
+type Interpreter struct {
+	Stdout      io.Writer
+	Stdin       *bufio.Reader
+	Importer    Importer   // nil when the host does not support import statements
+	Random      *rand.Rand // Reseeded by the seed() native
+	Permissions Permissions
+
+	MaxCallDepth int // Calls nested deeper than this raise a stack overflow error
+	calls        int // Calls currently running
+
+	// Limits ending a run with an *errors.LimitError, checked as loops go
+	// round and functions are called. Zero means no limit.
+	MaxSteps    int
+	MaxMemory   uint64 // Bytes the heap may grow by during a run
+	context     context.Context
+	runs        int // Runs going on, more than one when a run starts another
+	steps       int
+	heapAtStart uint64
+}
+
+// DefaultMaxCallDepth is how deeply calls may nest unless the host sets
+// another limit. It leaves the tree walker well inside the Go stack.
+const DefaultMaxCallDepth = 10000
+
+// Importer loads the modules import statements name. from is the file of the
+// importing module, empty for source that was not read from a file.
+type Importer interface {
+	Import(path string, from string) (*ModuleValue, error)
+}
+
+func newInterpreter() *Interpreter {
+	return &Interpreter{
+		Stdout: os.Stdout,
+		Stdin:  bufio.NewReader(os.Stdin),
+		Random: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+
+		MaxCallDepth: DefaultMaxCallDepth,
+	}
+}
+
+// EnterCall counts a call starting as a step of the run, raising a stack
+// overflow error instead when it would nest deeper than MaxCallDepth. Every
+// call entered must be left with LeaveCall, however it ends.
+func (i *Interpreter) EnterCall() {
+	i.Step()
+	if i.calls >= i.MaxCallDepth {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Stack overflow: calls nested deeper than the limit of %d", i.MaxCallDepth))
+	}
+	i.calls++
+}
+
+// LeaveCall counts a call entered with EnterCall ending.
+func (i *Interpreter) LeaveCall() {
+	i.calls--
+}
+
+// stepsBetweenChecks is how often Step looks at the context and the heap,
+// which cost far more than counting a step.
+const stepsBetweenChecks = 1024
+
+// Begin starts a run that ends early once ctx is done or the run exceeds
+// the interpreter's limits. A run begun while another is going on, such as
+// by a Go function the script called, is part of it and shares its limits.
+// Every run begun must be ended with End.
+func (i *Interpreter) Begin(ctx context.Context) {
+	i.runs++
+	if i.runs > 1 {
+		return
+	}
+
+	i.context = ctx
+	i.steps = 0
+	i.heapAtStart = heapSize()
+}
+
+// End ends a run begun with Begin.
+func (i *Interpreter) End() {
+	i.runs--
+}
+
+// Step counts a step of the running script: a call, or a loop going round.
+// It raises an *errors.LimitError once the run has to stop.
+func (i *Interpreter) Step() {
+	i.steps++
+	if i.MaxSteps > 0 && i.steps > i.MaxSteps {
+		errors.RaiseLimit(fmt.Sprintf("the script ran for more than %d steps", i.MaxSteps), nil)
+	}
+
+	if i.steps%stepsBetweenChecks != 0 {
+		return
+	}
+
+	if i.context != nil {
+		if err := i.context.Err(); err != nil {
+			errors.RaiseLimit(err.Error(), err)
+		}
+	}
+
+	if i.MaxMemory > 0 && i.heapUsed() > i.MaxMemory {
+		// Garbage counts until it is collected, so collect it before failing ---
+		goruntime.GC()
+		if i.heapUsed() > i.MaxMemory {
+			errors.RaiseLimit(fmt.Sprintf("the script used more than %d bytes of memory", i.MaxMemory), nil)
+		}
+	}
+}
+
+// MaxAllocation is the most bytes a single value built by a native may take
+// up when the interpreter has no memory limit, and the longest string + may
+// build. It keeps one call from exhausting the host's memory before Step
+// looks at it.
+const MaxAllocation = 1 << 30
+
+// valueSize is roughly how many bytes an array element takes up: the
+// interface in the slice and the value it points to.
+const valueSize = 32
+
+// Allocate raises an *errors.LimitError when name, a native about to build a
+// value of count items of size bytes each, would use more memory than the
+// run has left, or more than MaxAllocation without a memory limit. Natives
+// call it before building values whose size their arguments decide.
+func (i *Interpreter) Allocate(name string, count int, size int) {
+	if count <= 0 || size <= 0 {
+		return
+	}
+
+	available := uint64(MaxAllocation)
+	if i.MaxMemory > 0 {
+		available = i.MaxMemory - min(i.heapUsed(), i.MaxMemory)
+		if uint64(count) > available/uint64(size) {
+			// Garbage counts until it is collected, so collect it before failing ---
+			goruntime.GC()
+			available = i.MaxMemory - min(i.heapUsed(), i.MaxMemory)
+		}
+	}
+
+	if uint64(count) > available/uint64(size) {
+		errors.RaiseLimit(fmt.Sprintf("%s() would need more than the %d bytes of memory it may use", name, available), nil)
+	}
+}
+
+// heapUsed returns how far the heap has grown since the run began.
+func (i *Interpreter) heapUsed() uint64 {
+	size := heapSize()
+	return size - min(size, i.heapAtStart)
+}
+
+// heapSize returns the bytes of heap the process's objects take up. It is
+// cheap to read, unlike runtime.ReadMemStats.
+func heapSize() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// EvaluateExpression evaluates node in env. Runtime failures are returned as
+// an *errors.RuntimeError instead of terminating the process.
+func EvaluateExpression(node ast.Expression, env Environment) (result RuntimeValue, err error) {
+	defer errors.Recover(&err)
+	return evaluateExpression(node, env), nil
+}
+
+// EvaluateStatement evaluates node in env. Runtime failures are returned as
+// an *errors.RuntimeError instead of terminating the process. The program
+// node belongs to must have been passed through resolver.Resolve first.
+func EvaluateStatement(node ast.Statement, env Environment) (result RuntimeValue, err error) {
+	defer errors.Recover(&err)
+	return evaluateStatement(node, env), nil
+}
+
+// evaluateExpression evaluates node in env, locating errors it raises at node.
+// The single return lets Go open-code the defer, which keeps it cheap.
+func evaluateExpression(node ast.Expression, env Environment) RuntimeValue {
+	defer locate(node, env)
+	return evaluateExpressionNode(node, env)
+}
+
+func evaluateExpressionNode(node ast.Expression, env Environment) RuntimeValue {
 	switch n := node.(type) {
 	case *ast.NumberExpression:
 		return evaluateNumberExpression(n)
@@ -32,22 +218,39 @@ func EvaluateExpression(node ast.Expression, env Environment) RuntimeValue {
 		return evaluateIndexAssignmentExpression(n, env)
 	case *ast.CallExpression:
 		return evaluateCallExpression(n, env)
+	case *ast.MapExpression:
+		return evaluateMapExpression(n, env)
+	case *ast.MemberExpression:
+		return evaluateMemberExpression(n, env)
+	case *ast.MemberAssignmentExpression:
+		return evaluateMemberAssignmentExpression(n, env)
+	case *ast.FunctionExpression:
+		return FUNCTION("anonymous", n.Parameters, n.Body, env)
+	case *ast.ThisExpression:
+		return evaluateThisExpression(n, env)
+	case *ast.SuperExpression:
+		return evaluateSuperExpression(n, env)
 
 	default:
-		litter.Dump(fmt.Sprintf("Unsupported expression node type %v\n", node))
-		litter.Dump(node)
-		os.Exit(65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("Unsupported expression node type %T", node))
 	}
 
 	return nil
 }
 
-func EvaluateStatement(node ast.Statement, env Environment) RuntimeValue {
+// evaluateStatement evaluates node in env, locating errors it raises at node,
+// like evaluateExpression.
+func evaluateStatement(node ast.Statement, env Environment) RuntimeValue {
+	defer locate(node, env)
+	return evaluateStatementNode(node, env)
+}
+
+func evaluateStatementNode(node ast.Statement, env Environment) RuntimeValue {
 	switch n := node.(type) {
 	case *ast.BlockStatement:
 		return evaluateBlockStatement(n, env)
 	case *ast.ExpressionStatement:
-		return EvaluateExpression(n.Expression, env)
+		return evaluateExpression(n.Expression, env)
 	case *ast.VariableDeclarationStatement:
 		return evaluateVariableDeclarationStatement(n, env)
 	case *ast.IfStatement:
@@ -56,15 +259,29 @@ func EvaluateStatement(node ast.Statement, env Environment) RuntimeValue {
 		return evaluateWhileStatement(n, env)
 	case *ast.ForStatement:
 		return evaluateForStatement(n, env)
+	case *ast.ForInStatement:
+		return evaluateForInStatement(n, env)
 	case *ast.FunctionDeclaration:
 		return evaluateFunctionDeclaration(n, env)
+	case *ast.ClassDeclaration:
+		return evaluateClassDeclaration(n, env)
 	case *ast.ReturnStatement:
 		return evaluateReturnStatement(n, env)
+	case *ast.BreakStatement:
+		return &BreakValue{Label: n.Label}
+	case *ast.ContinueStatement:
+		return &ContinueValue{Label: n.Label}
+	case *ast.ImportStatement:
+		return evaluateImportStatement(n, env)
+	case *ast.ExportStatement:
+		return evaluateStatement(n.Declaration, env)
+	case *ast.ThrowStatement:
+		return evaluateThrowStatement(n, env)
+	case *ast.TryStatement:
+		return evaluateTryStatement(n, env)
 
 	default:
-		litter.Dump(fmt.Sprintf("Unsupported Statement node type %V\n", node))
-		litter.Dump(node)
-		os.Exit(65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("Unsupported statement node type %T", node))
 	}
 
 	return nil
@@ -0,0 +1,210 @@
+package runtime_test
+
+import (
+	"bytes"
+	"testing"
+
+	mutex "github.com/caelondev/mutex/src"
+)
+
+// backends are the execution backends every program runs on, by name.
+var backends = map[string]mutex.Backend{
+	"tree": mutex.TreeWalker,
+	"vm":   mutex.BytecodeVM,
+}
+
+// TestControlFlow checks that return, break and continue leave exactly the
+// statements they should, however deeply they are nested.
+func TestControlFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{
+			name: "return from a while loop",
+			source: `
+fn root(limit) {
+  var mut i = 0;
+  while (true) {
+    if (i * i > limit) {
+      return i;
+    }
+    i++;
+  }
+  return -1;
+}
+echo(root(50));`,
+			output: "8\n",
+		},
+		{
+			name: "return from nested for loops",
+			source: `
+fn pair(target) {
+  for (var mut a = 0; a < 10; a++) {
+    for (var mut b = 0; b < 10; b++) {
+      if (a * b == target) {
+        return [a, b];
+      }
+    }
+  }
+  return nil;
+}
+echo(pair(12));
+echo(pair(97));`,
+			output: "[2, 6]\nnil\n",
+		},
+		{
+			name: "return from a for-in loop inside a while loop",
+			source: `
+fn first(rows) {
+  var mut row = 0;
+  while (row < len(rows)) {
+    for (var imm cell in rows[row]) {
+      if (cell < 0) {
+        return [row, cell];
+      }
+    }
+    row++;
+  }
+  return "none";
+}
+echo(first([[1, 2], [3, -4, -5], [-6]]));
+echo(first([[1]]));`,
+			output: "[1, -4]\n\"none\"\n",
+		},
+		{
+			name: "loop keeps going without a return",
+			source: `
+fn count() {
+  var mut n = 0;
+  for (var mut i = 0; i < 5; i++) {
+    if (i == 10) {
+      return -1;
+    }
+    n++;
+  }
+  return n;
+}
+echo(count());`,
+			output: "5\n",
+		},
+		{
+			name: "return from an if/else chain",
+			source: `
+fn grade(score) {
+  if (score >= 90) {
+    return "A";
+  } else if (score >= 80) {
+    return "B";
+  } else if (score >= 70) {
+    if (score == 75) {
+      return "C+";
+    }
+    return "C";
+  } else {
+    return "F";
+  }
+  return "unreachable";
+}
+echo(grade(95), grade(85), grade(75), grade(72), grade(10));`,
+			output: "\"A\" \"B\" \"C+\" \"C\" \"F\"\n",
+		},
+		{
+			name: "return from nested blocks",
+			source: `
+fn nested() {
+  {
+    echo("outer");
+    {
+      echo("inner");
+      return "done";
+      echo("after return");
+    }
+    echo("after inner block");
+  }
+  echo("after outer block");
+}
+echo(nested());`,
+			output: "\"outer\"\n\"inner\"\n\"done\"\n",
+		},
+		{
+			name: "return ends only the innermost function",
+			source: `
+fn inner() {
+  for (var mut i = 0; i < 3; i++) {
+    return i;
+  }
+}
+fn outer() {
+  var mut total = 0;
+  for (var mut i = 0; i < 3; i++) {
+    total = total + inner() + 1;
+  }
+  return total;
+}
+echo(outer());`,
+			output: "3\n",
+		},
+		{
+			name: "break and continue in nested loops",
+			source: `
+var mut seen = [];
+outer: for (var mut row = 0; row < 4; row++) {
+  for (var mut col = 0; col < 4; col++) {
+    if (col == 1) {
+      continue;
+    }
+    if (col > row) {
+      continue outer;
+    }
+    if (row == 3) {
+      break outer;
+    }
+    push(seen, [row, col]);
+  }
+}
+echo(seen);
+var mut n = 0;
+while (true) {
+  n++;
+  {
+    if (n < 3) {
+      continue;
+    }
+    break;
+  }
+}
+echo(n);`,
+			output: "[[0, 0], [1, 0], [2, 0], [2, 2]]\n3\n",
+		},
+		{
+			name: "top-level return ends the program",
+			source: `
+echo("before");
+for (var mut i = 0; i < 5; i++) {
+  if (i == 2) {
+    return i * 100;
+  }
+}
+echo("unreachable");`,
+			output: "\"before\"\n",
+		},
+	}
+
+	for name, backend := range backends {
+		for _, test := range tests {
+			t.Run(name+"/"+test.name, func(t *testing.T) {
+				var stdout bytes.Buffer
+				interpreter := mutex.NewInterpreter(mutex.WithBackend(backend), mutex.WithStdout(&stdout))
+				if _, err := interpreter.Eval(test.source); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if stdout.String() != test.output {
+					t.Errorf("printed %q, want %q", stdout.String(), test.output)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/caelondev/mutex/src/errors"
+)
+
+// Methods reachable through `value.name(...)` on built-in types. Each one is
+// an ordinary native function that receives the value as its first argument,
+// so `arr.push(1)` runs exactly like `push(arr, 1)`.
+var ARRAY_METHODS map[string]func([]RuntimeValue, Environment) RuntimeValue
+
+// Some array methods call functions, and calling a function can look methods
+// up again, so the table is filled in by init to avoid an initialization cycle.
+func init() {
+	ARRAY_METHODS = map[string]func([]RuntimeValue, Environment) RuntimeValue{
+		"push":     NATIVE_PUSH_FUNCTION,
+		"pop":      NATIVE_POP_FUNCTION,
+		"shift":    NATIVE_SHIFT_FUNCTION,
+		"unshift":  NATIVE_UNSHIFT_FUNCTION,
+		"join":     NATIVE_JOIN_FUNCTION,
+		"map":      NATIVE_MAP_FUNCTION,
+		"filter":   NATIVE_FILTER_FUNCTION,
+		"reduce":   NATIVE_REDUCE_FUNCTION,
+		"find":     NATIVE_FIND_FUNCTION,
+		"any":      NATIVE_ANY_FUNCTION,
+		"all":      NATIVE_ALL_FUNCTION,
+		"sort":     NATIVE_SORT_FUNCTION,
+		"reverse":  NATIVE_REVERSE_FUNCTION,
+		"slice":    NATIVE_SLICE_FUNCTION,
+		"concat":   NATIVE_CONCAT_FUNCTION,
+		"index_of": NATIVE_INDEX_OF_FUNCTION,
+		"contains": NATIVE_CONTAINS_FUNCTION,
+	}
+}
+
+var STRING_METHODS = map[string]func([]RuntimeValue, Environment) RuntimeValue{
+	"upper":       NATIVE_UPPER_FUNCTION,
+	"lower":       NATIVE_LOWER_FUNCTION,
+	"trim":        NATIVE_TRIM_FUNCTION,
+	"split":       NATIVE_SPLIT_FUNCTION,
+	"substr":      NATIVE_SUBSTR_FUNCTION,
+	"index_of":    NATIVE_INDEX_OF_FUNCTION,
+	"replace":     NATIVE_REPLACE_FUNCTION,
+	"starts_with": NATIVE_STARTS_WITH_FUNCTION,
+	"ends_with":   NATIVE_ENDS_WITH_FUNCTION,
+	"repeat":      NATIVE_REPEAT_FUNCTION,
+	"chars":       NATIVE_CHARS_FUNCTION,
+	"contains":    NATIVE_CONTAINS_FUNCTION,
+}
+
+// Properties reachable through `value.name` on built-in types.
+var ARRAY_PROPERTIES = map[string]func(*ArrayValue) RuntimeValue{
+	"length": func(a *ArrayValue) RuntimeValue { return &NumberValue{Value: float64(len(a.Elements))} },
+}
+
+var STRING_PROPERTIES = map[string]func(*StringValue) RuntimeValue{
+	"length": func(s *StringValue) RuntimeValue {
+		return &NumberValue{Value: float64(utf8.RuneCountInString(s.Value))}
+	},
+}
+
+var ERROR_PROPERTIES = map[string]func(*ErrorValue) RuntimeValue{
+	"message": func(e *ErrorValue) RuntimeValue { return &StringValue{Value: e.Message} },
+	"kind":    func(e *ErrorValue) RuntimeValue { return &StringValue{Value: e.Kind} },
+	"stack":   errorStack,
+}
+
+// builtinMember resolves `object.name` for arrays, strings and errors.
+func builtinMember(object RuntimeValue, name string) RuntimeValue {
+	switch value := object.(type) {
+	case *ArrayValue:
+		if property, ok := ARRAY_PROPERTIES[name]; ok {
+			return property(value)
+		}
+		if method, ok := ARRAY_METHODS[name]; ok {
+			return bindNative(name, method, value)
+		}
+	case *StringValue:
+		if property, ok := STRING_PROPERTIES[name]; ok {
+			return property(value)
+		}
+		if method, ok := STRING_METHODS[name]; ok {
+			return bindNative(name, method, value)
+		}
+	case *ErrorValue:
+		if property, ok := ERROR_PROPERTIES[name]; ok {
+			return property(value)
+		}
+	default:
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot access property '%s' on type '%s'", name, object.Type()))
+	}
+
+	errors.RaiseRuntime(nil, fmt.Sprintf("Type '%s' has no property '%s'", object.Type(), name))
+	return NIL()
+}
+
+// bindNative returns a native function that calls method with receiver
+// prepended to its arguments. Every access binds anew, so natives bound to
+// the same receiver and method compare equal.
+func bindNative(name string, method func([]RuntimeValue, Environment) RuntimeValue, receiver RuntimeValue) *NativeFunctionValue {
+	native := NATIVE_FUNCTION(name, func(args []RuntimeValue, env Environment) RuntimeValue {
+		return method(append([]RuntimeValue{receiver}, args...), env)
+	})
+	native.Receiver = receiver
+	return native
+}
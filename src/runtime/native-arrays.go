@@ -0,0 +1,296 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/caelondev/mutex/src/errors"
+)
+
+func NATIVE_MAP_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	array, function := arrayAndFunction("map", args)
+
+	elements := make([]RuntimeValue, len(array.Elements))
+	for i, element := range array.Elements {
+		elements[i] = CallValue(function, []RuntimeValue{element}, env)
+	}
+	return ARRAY(elements)
+}
+
+func NATIVE_FILTER_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	array, function := arrayAndFunction("filter", args)
+
+	elements := []RuntimeValue{}
+	for _, element := range array.Elements {
+		if IsTruthy(CallValue(function, []RuntimeValue{element}, env)) {
+			elements = append(elements, element)
+		}
+	}
+	return ARRAY(elements)
+}
+
+func NATIVE_REDUCE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 && len(args) != 3 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("reduce() expects 2 or 3 arguments (array, function, initial) but got %d", len(args)))
+	}
+	array, function := arrayAndFunction("reduce", args[:2])
+	elements := array.Elements
+
+	// Without an initial value the first element starts the reduction
+	var accumulator RuntimeValue
+	if len(args) == 3 {
+		accumulator = args[2]
+	} else {
+		if len(elements) == 0 {
+			errors.RaiseRuntime(nil, "reduce() of an empty array needs an initial value")
+		}
+		accumulator, elements = elements[0], elements[1:]
+	}
+
+	for _, element := range elements {
+		accumulator = CallValue(function, []RuntimeValue{accumulator, element}, env)
+	}
+	return accumulator
+}
+
+func NATIVE_FIND_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	array, function := arrayAndFunction("find", args)
+
+	for _, element := range array.Elements {
+		if IsTruthy(CallValue(function, []RuntimeValue{element}, env)) {
+			return element
+		}
+	}
+	return NIL()
+}
+
+func NATIVE_ANY_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	array, function := arrayAndFunction("any", args)
+
+	for _, element := range array.Elements {
+		if IsTruthy(CallValue(function, []RuntimeValue{element}, env)) {
+			return BOOLEAN(true)
+		}
+	}
+	return BOOLEAN(false)
+}
+
+func NATIVE_ALL_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	array, function := arrayAndFunction("all", args)
+
+	for _, element := range array.Elements {
+		if !IsTruthy(CallValue(function, []RuntimeValue{element}, env)) {
+			return BOOLEAN(false)
+		}
+	}
+	return BOOLEAN(true)
+}
+
+// NATIVE_SORT_FUNCTION returns a sorted copy of an array. The comparator gets
+// two elements and returns a negative number when the first goes first, a
+// positive one when the second does and 0 to keep their order.
+func NATIVE_SORT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 && len(args) != 2 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("sort() expects 1 or 2 arguments (array, comparator) but got %d", len(args)))
+	}
+
+	compare := compareValues
+	if len(args) == 2 {
+		_, function := arrayAndFunction("sort", args)
+		compare = func(a RuntimeValue, b RuntimeValue) int {
+			result, ok := CallValue(function, []RuntimeValue{a, b}, env).(*NumberValue)
+			if !ok {
+				errors.RaiseRuntime(nil, "sort() comparator must return a number")
+			}
+
+			switch {
+			case result.Value < 0:
+				return -1
+			case result.Value > 0:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	elements := slices.Clone(arrayArgument("sort", args[0]).Elements)
+	slices.SortStableFunc(elements, compare)
+	return ARRAY(elements)
+}
+
+// compareValues orders numbers and strings for sort() without a comparator.
+func compareValues(a RuntimeValue, b RuntimeValue) int {
+	switch left := a.(type) {
+	case *NumberValue:
+		if right, ok := b.(*NumberValue); ok {
+			switch {
+			case left.Value < right.Value:
+				return -1
+			case left.Value > right.Value:
+				return 1
+			}
+			return 0
+		}
+	case *StringValue:
+		if right, ok := b.(*StringValue); ok {
+			return strings.Compare(left.Value, right.Value)
+		}
+	}
+
+	errors.RaiseRuntime(nil, fmt.Sprintf("sort() cannot compare '%s' with '%s' without a comparator", a.Type(), b.Type()))
+	return 0
+}
+
+func NATIVE_REVERSE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("reverse() expects exactly 1 argument but got %d", len(args)))
+	}
+
+	elements := slices.Clone(arrayArgument("reverse", args[0]).Elements)
+	slices.Reverse(elements)
+	return ARRAY(elements)
+}
+
+func NATIVE_SLICE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 && len(args) != 3 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("slice() expects 2 or 3 arguments (array, start, end) but got %d", len(args)))
+	}
+	elements := arrayArgument("slice", args[0]).Elements
+
+	// Without an end the rest of the array is taken
+	start := integerArgument("slice", args[1])
+	end := len(elements)
+	if len(args) == 3 {
+		end = integerArgument("slice", args[2])
+	}
+
+	if start < 0 || end > len(elements) || start > end {
+		errors.RaiseRuntime(nil, fmt.Sprintf("slice() range %d to %d out of bounds (array length: %d)", start, end, len(elements)))
+	}
+
+	return ARRAY(slices.Clone(elements[start:end]))
+}
+
+func NATIVE_CONCAT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	elements := []RuntimeValue{}
+	for _, arg := range args {
+		elements = append(elements, arrayArgument("concat", arg).Elements...)
+	}
+	return ARRAY(elements)
+}
+
+func NATIVE_CONTAINS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, "contains() expects exactly 2 arguments (array or string, value)")
+	}
+
+	// Strings look for a part of themselves
+	if _, ok := args[0].(*StringValue); ok {
+		strs := stringArguments("contains", args, 2)
+		return BOOLEAN(strings.Contains(strs[0], strs[1]))
+	}
+
+	return BOOLEAN(indexOf(arrayArgument("contains", args[0]), args[1]) >= 0)
+}
+
+// NATIVE_RANGE_FUNCTION returns the numbers from start up to, but not
+// including, end: range(end), range(start, end) or range(start, end, step).
+func NATIVE_RANGE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) < 1 || len(args) > 3 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("range() expects 1 to 3 arguments (start, end, step) but got %d", len(args)))
+	}
+	numbers := numberArguments("range", args, len(args))
+
+	start, end, step := 0.0, numbers[0], 1.0
+	if len(numbers) > 1 {
+		start, end = numbers[0], numbers[1]
+	}
+	if len(numbers) > 2 {
+		step = numbers[2]
+	}
+	if step == 0 {
+		errors.RaiseRuntime(nil, "range() step cannot be 0")
+	}
+
+	// NaN bounds give no numbers, infinite ones too many ---
+	count := 0
+	if span := math.Ceil((end - start) / step); span > 0 {
+		count = int(min(span, math.MaxInt32))
+		env.Interpreter().Allocate("range", count, valueSize)
+	}
+
+	// Counted rather than stepped, as adding a small step to a large number
+	// can leave it unchanged ---
+	elements := make([]RuntimeValue, count)
+	for i := range count {
+		elements[i] = NUMBER(start + float64(i)*step)
+	}
+	return ARRAY(elements)
+}
+
+// indexOf returns the index of the first element of array equal to value,
+// or -1.
+func indexOf(array *ArrayValue, value RuntimeValue) int {
+	return slices.IndexFunc(array.Elements, func(element RuntimeValue) bool {
+		return valuesEqual(element, value)
+	})
+}
+
+// valuesEqual compares numbers, strings, booleans and nil by value, built-in
+// methods by their receiver and name, and every other value by identity.
+func valuesEqual(a RuntimeValue, b RuntimeValue) bool {
+	switch left := a.(type) {
+	case *NumberValue:
+		right, ok := b.(*NumberValue)
+		return ok && left.Value == right.Value
+	case *StringValue:
+		right, ok := b.(*StringValue)
+		return ok && left.Value == right.Value
+	case *BooleanValue:
+		right, ok := b.(*BooleanValue)
+		return ok && left.Value == right.Value
+	case *NilValue:
+		_, ok := b.(*NilValue)
+		return ok
+	case *NativeFunctionValue:
+		right, ok := b.(*NativeFunctionValue)
+		if ok && left.Receiver != nil && right.Receiver != nil {
+			return left.Name == right.Name && valuesEqual(left.Receiver, right.Receiver)
+		}
+	}
+	return a == b
+}
+
+// arrayAndFunction checks that a native received exactly an array and a
+// function to call for its elements, and returns both.
+func arrayAndFunction(name string, args []RuntimeValue) (*ArrayValue, RuntimeValue) {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects exactly 2 arguments (array, function) but got %d", name, len(args)))
+	}
+
+	array := arrayArgument(name, args[0])
+	if !isCallable(args[1]) {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects a function, got '%s'", name, args[1].Type()))
+	}
+
+	return array, args[1]
+}
+
+func arrayArgument(name string, arg RuntimeValue) *ArrayValue {
+	array, ok := arg.(*ArrayValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects an array, got '%s'", name, arg.Type()))
+	}
+	return array
+}
+
+// isCallable reports whether CallValue can call value.
+func isCallable(value RuntimeValue) bool {
+	switch value.(type) {
+	case *NativeFunctionValue, *FunctionValue, Callable, *ClassValue:
+		return true
+	}
+	return false
+}
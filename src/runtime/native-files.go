@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/caelondev/mutex/src/errors"
+)
+
+// Permissions lists the directories file natives may use. Everything inside
+// a listed directory is allowed, and nothing is allowed by default, so a host
+// can run untrusted scripts without giving them the filesystem.
+type Permissions struct {
+	Read  []string
+	Write []string
+}
+
+func NATIVE_READ_FILE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	path := stringArgument("read_file", args, 1).Value
+	checkAccess("read", env.Interpreter().Permissions.Read, path)
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		errors.RaiseRuntime(nil, fmt.Sprintf("read_file() failed: %v", err))
+	}
+	return &StringValue{Value: string(bytes)}
+}
+
+func NATIVE_WRITE_FILE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	strs := stringArguments("write_file", args, 2)
+	checkAccess("write", env.Interpreter().Permissions.Write, strs[0])
+
+	// Replaces the file's contents, creating it if needed
+	if err := os.WriteFile(strs[0], []byte(strs[1]), 0o644); err != nil {
+		errors.RaiseRuntime(nil, fmt.Sprintf("write_file() failed: %v", err))
+	}
+	return NIL()
+}
+
+func NATIVE_APPEND_FILE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	strs := stringArguments("append_file", args, 2)
+	checkAccess("write", env.Interpreter().Permissions.Write, strs[0])
+
+	file, err := os.OpenFile(strs[0], os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = file.WriteString(strs[1])
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		errors.RaiseRuntime(nil, fmt.Sprintf("append_file() failed: %v", err))
+	}
+	return NIL()
+}
+
+func NATIVE_EXISTS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	path := stringArgument("exists", args, 1).Value
+	checkAccess("read", env.Interpreter().Permissions.Read, path)
+
+	_, err := os.Stat(path)
+	return BOOLEAN(err == nil)
+}
+
+func NATIVE_LIST_DIR_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	path := stringArgument("list_dir", args, 1).Value
+	checkAccess("read", env.Interpreter().Permissions.Read, path)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		errors.RaiseRuntime(nil, fmt.Sprintf("list_dir() failed: %v", err))
+	}
+
+	// Sorted by name
+	names := make([]RuntimeValue, len(entries))
+	for i, entry := range entries {
+		names[i] = &StringValue{Value: entry.Name()}
+	}
+	return ARRAY(names)
+}
+
+// NATIVE_READ_LINE_FUNCTION reads the next line of standard input without its
+// line ending, or returns nil once the input is exhausted.
+func NATIVE_READ_LINE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 0 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("read_line() expects no arguments but got %d", len(args)))
+	}
+
+	stdin := env.Interpreter().Stdin
+	if stdin == nil {
+		return NIL()
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err != io.EOF {
+			errors.RaiseRuntime(nil, fmt.Sprintf("read_line() failed: %v", err))
+		}
+		return NIL()
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	return &StringValue{Value: strings.TrimSuffix(line, "\r")}
+}
+
+// checkAccess raises an error unless path lies inside one of dirs. Symbolic
+// links are followed first, so a link cannot lead outside the allowed
+// directories.
+func checkAccess(access string, dirs []string, path string) {
+	if !Allowed(dirs, path) {
+		errors.RaiseRuntime(nil, fmt.Sprintf("No %s access to \"%s\"", access, path))
+	}
+}
+
+// Allowed reports whether path lies inside one of dirs, following symbolic
+// links the way the file natives do.
+func Allowed(dirs []string, path string) bool {
+	target := realPath(path)
+
+	return slices.ContainsFunc(dirs, func(dir string) bool {
+		relative, err := filepath.Rel(realPath(dir), target)
+		return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+	})
+}
+
+// realPath returns the absolute form of path with every symbolic link in its
+// existing part resolved. The part that does not exist yet is kept as is.
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	missing := ""
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, missing)
+		}
+
+		if parent := filepath.Dir(dir); parent == dir {
+			return abs
+		}
+		missing = filepath.Join(filepath.Base(dir), missing)
+	}
+}
@@ -2,40 +2,61 @@ package runtime
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/caelondev/mutex/src/errors"
 )
 
 func NATIVE_ECHO_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	stdout := env.Interpreter().Stdout
+
 	for i, arg := range args {
-		fmt.Print(arg.String())
+		fmt.Fprint(stdout, arg.String())
 		if i < len(args)-1 {
-			fmt.Print(" ")
+			fmt.Fprint(stdout, " ")
 		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(stdout)
 	return NIL()
 }
 
 func NATIVE_TYPEOF_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) != 1 {
-		errors.ReportInterpreter(fmt.Sprintf("typeof() expects 1 argument but got %d instead...", len(args)), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("typeof() expects 1 argument but got %d instead...", len(args)))
 	}
 
 	return &StringValue{ Value: string(args[0].Type()) }
 }
 
+// NATIVE_ERROR_FUNCTION makes an error value to throw. Its kind defaults to
+// "Error".
+func NATIVE_ERROR_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 && len(args) != 2 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("error() expects 1 or 2 arguments but got %d", len(args)))
+	}
+
+	strs := stringArguments("error", args, len(args))
+	kind := "Error"
+	if len(strs) == 2 {
+		kind = strs[1]
+	}
+
+	return &ErrorValue{Kind: kind, Message: strs[0]}
+}
+
 func NATIVE_PUSH_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) < 2 {
-		errors.ReportInterpreter("push() expects at least 2 arguments (array, value)", 65)
+		errors.RaiseRuntime(nil, "push() expects at least 2 arguments (array, value)")
 		return NIL()
 	}
 
 	arrayValue, ok := args[0].(*ArrayValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("push() expects an array as first argument, got '%s'", args[0].Type()), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("push() expects an array as first argument, got '%s'", args[0].Type()))
 		return NIL()
 	}
 
@@ -49,18 +70,18 @@ func NATIVE_PUSH_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 
 func NATIVE_POP_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) != 1 {
-		errors.ReportInterpreter("pop() expects exactly 1 argument (array)", 65)
+		errors.RaiseRuntime(nil, "pop() expects exactly 1 argument (array)")
 		return NIL()
 	}
 
 	arrayValue, ok := args[0].(*ArrayValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("pop() expects an array, got '%s'", args[0].Type()), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("pop() expects an array, got '%s'", args[0].Type()))
 		return NIL()
 	}
 
 	if len(arrayValue.Elements) == 0 {
-		errors.ReportInterpreter("pop() called on empty array", 65)
+		errors.RaiseRuntime(nil, "pop() called on empty array")
 		return NIL()
 	}
 
@@ -75,18 +96,18 @@ func NATIVE_POP_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 
 func NATIVE_SHIFT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) != 1 {
-		errors.ReportInterpreter("shift() expects exactly 1 argument (array)", 65)
+		errors.RaiseRuntime(nil, "shift() expects exactly 1 argument (array)")
 		return NIL()
 	}
 
 	arrayValue, ok := args[0].(*ArrayValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("shift() expects an array, got '%s'", args[0].Type()), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("shift() expects an array, got '%s'", args[0].Type()))
 		return NIL()
 	}
 
 	if len(arrayValue.Elements) == 0 {
-		errors.ReportInterpreter("shift() called on empty array", 65)
+		errors.RaiseRuntime(nil, "shift() called on empty array")
 		return NIL()
 	}
 
@@ -101,13 +122,13 @@ func NATIVE_SHIFT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 
 func NATIVE_UNSHIFT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) < 2 {
-		errors.ReportInterpreter("unshift() expects at least 2 arguments (array, value)", 65)
+		errors.RaiseRuntime(nil, "unshift() expects at least 2 arguments (array, value)")
 		return NIL()
 	}
 
 	arrayValue, ok := args[0].(*ArrayValue)
 	if !ok {
-		errors.ReportInterpreter(fmt.Sprintf("unshift() expects an array as first argument, got '%s'", args[0].Type()), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("unshift() expects an array as first argument, got '%s'", args[0].Type()))
 		return NIL()
 	}
 
@@ -125,7 +146,7 @@ func NATIVE_UNSHIFT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue
 
 func NATIVE_STRING_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) != 1 {
-		errors.ReportInterpreter("string() expects exactly 1 argument", 65)
+		errors.RaiseRuntime(nil, "string() expects exactly 1 argument")
 		return NIL()
 	}
 
@@ -135,7 +156,7 @@ func NATIVE_STRING_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 
 func NATIVE_INT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) != 1 {
-		errors.ReportInterpreter("int() expects exactly 1 argument", 65)
+		errors.RaiseRuntime(nil, "int() expects exactly 1 argument")
 		return NIL()
 	}
 
@@ -147,7 +168,7 @@ func NATIVE_INT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 		// Try to parse string as number
 		parsed, err := strconv.ParseFloat(v.Value, 64)
 		if err != nil {
-			errors.ReportInterpreter(fmt.Sprintf("Cannot convert string '%s' to int", v.Value), 65)
+			errors.RaiseRuntime(nil, fmt.Sprintf("Cannot convert string '%s' to int", v.Value))
 			return NIL()
 		}
 		return &NumberValue{Value: float64(int(parsed))}
@@ -157,14 +178,14 @@ func NATIVE_INT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 		}
 		return &NumberValue{Value: 0}
 	default:
-		errors.ReportInterpreter(fmt.Sprintf("Cannot convert type '%s' to int", v.Type()), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot convert type '%s' to int", v.Type()))
 		return NIL()
 	}
 }
 
 func NATIVE_FLOAT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) != 1 {
-		errors.ReportInterpreter("float() expects exactly 1 argument", 65)
+		errors.RaiseRuntime(nil, "float() expects exactly 1 argument")
 		return NIL()
 	}
 
@@ -176,7 +197,7 @@ func NATIVE_FLOAT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 		// Try to parse string as number
 		parsed, err := strconv.ParseFloat(v.Value, 64)
 		if err != nil {
-			errors.ReportInterpreter(fmt.Sprintf("Cannot convert string '%s' to float", v.Value), 65)
+			errors.RaiseRuntime(nil, fmt.Sprintf("Cannot convert string '%s' to float", v.Value))
 			return NIL()
 		}
 		return &NumberValue{Value: parsed}
@@ -186,17 +207,315 @@ func NATIVE_FLOAT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 		}
 		return &NumberValue{Value: 0.0}
 	default:
-		errors.ReportInterpreter(fmt.Sprintf("Cannot convert type '%s' to float", v.Type()), 65)
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot convert type '%s' to float", v.Type()))
 		return NIL()
 	}
 }
 
 func NATIVE_BOOL_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
 	if len(args) != 1 {
-		errors.ReportInterpreter("bool() expects exactly 1 argument", 65)
+		errors.RaiseRuntime(nil, "bool() expects exactly 1 argument")
 		return NIL()
 	}
 
 	// Use the existing isTruthy function logic
-	return BOOLEAN(isTruthy(args[0]))
+	return BOOLEAN(IsTruthy(args[0]))
+}
+
+func NATIVE_KEYS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 {
+		errors.RaiseRuntime(nil, "keys() expects exactly 1 argument (map)")
+		return NIL()
+	}
+
+	mapValue, ok := args[0].(*MapValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("keys() expects a map, got '%s'", args[0].Type()))
+		return NIL()
+	}
+
+	keys := make([]RuntimeValue, len(mapValue.Keys))
+	for i, key := range mapValue.Keys {
+		keys[i] = &StringValue{Value: key}
+	}
+
+	return ARRAY(keys)
+}
+
+func NATIVE_VALUES_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 {
+		errors.RaiseRuntime(nil, "values() expects exactly 1 argument (map)")
+		return NIL()
+	}
+
+	mapValue, ok := args[0].(*MapValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("values() expects a map, got '%s'", args[0].Type()))
+		return NIL()
+	}
+
+	values := make([]RuntimeValue, len(mapValue.Keys))
+	for i, key := range mapValue.Keys {
+		values[i] = mapValue.Entries[key]
+	}
+
+	return ARRAY(values)
+}
+
+func NATIVE_HAS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, "has() expects exactly 2 arguments (map, key)")
+		return NIL()
+	}
+
+	mapValue, ok := args[0].(*MapValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("has() expects a map as first argument, got '%s'", args[0].Type()))
+		return NIL()
+	}
+
+	_, exists := mapValue.Get(mapKey(args[1]))
+	return BOOLEAN(exists)
+}
+
+func NATIVE_DELETE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, "delete() expects exactly 2 arguments (map, key)")
+		return NIL()
+	}
+
+	mapValue, ok := args[0].(*MapValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("delete() expects a map as first argument, got '%s'", args[0].Type()))
+		return NIL()
+	}
+
+	// Returns whether the key was there to delete
+	return BOOLEAN(mapValue.Delete(mapKey(args[1])))
+}
+
+func NATIVE_UPPER_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	str := stringArgument("upper", args, 1)
+	return &StringValue{Value: strings.ToUpper(str.Value)}
+}
+
+func NATIVE_LOWER_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	str := stringArgument("lower", args, 1)
+	return &StringValue{Value: strings.ToLower(str.Value)}
+}
+
+func NATIVE_TRIM_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	str := stringArgument("trim", args, 1)
+	return &StringValue{Value: strings.TrimSpace(str.Value)}
+}
+
+func NATIVE_SPLIT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, "split() expects exactly 2 arguments (string, separator)")
+		return NIL()
+	}
+
+	str := stringArgument("split", args[:1], 1)
+	separator, ok := args[1].(*StringValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("split() expects a string separator, got '%s'", args[1].Type()))
+		return NIL()
+	}
+
+	// An empty separator splits between every character
+	env.Interpreter().Allocate("split", strings.Count(str.Value, separator.Value)+1, valueSize)
+	parts := strings.Split(str.Value, separator.Value)
+
+	elements := make([]RuntimeValue, len(parts))
+	for i, part := range parts {
+		elements[i] = &StringValue{Value: part}
+	}
+
+	return ARRAY(elements)
+}
+
+func NATIVE_LEN_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("len() expects exactly 1 argument but got %d", len(args)))
+	}
+
+	switch value := args[0].(type) {
+	case *StringValue:
+		return NUMBER(float64(utf8.RuneCountInString(value.Value)))
+	case *ArrayValue:
+		return NUMBER(float64(len(value.Elements)))
+	case *MapValue:
+		return NUMBER(float64(len(value.Keys)))
+	}
+
+	errors.RaiseRuntime(nil, fmt.Sprintf("len() expects a string, array or map, got '%s'", args[0].Type()))
+	return NIL()
+}
+
+func NATIVE_SUBSTR_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 && len(args) != 3 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("substr() expects 2 or 3 arguments (string, start, length) but got %d", len(args)))
+	}
+
+	runes := []rune(stringArgument("substr", args[:1], 1).Value)
+
+	start := integerArgument("substr", args[1])
+	if start < 0 || start > len(runes) {
+		errors.RaiseRuntime(nil, fmt.Sprintf("substr() start %d out of bounds (string length: %d)", start, len(runes)))
+	}
+
+	// Without a length the rest of the string is taken
+	length := len(runes) - start
+	if len(args) == 3 {
+		length = integerArgument("substr", args[2])
+		if length < 0 || start+length > len(runes) {
+			errors.RaiseRuntime(nil, fmt.Sprintf("substr() length %d out of bounds (%d characters left after start)", length, len(runes)-start))
+		}
+	}
+
+	return &StringValue{Value: string(runes[start : start+length])}
+}
+
+func NATIVE_INDEX_OF_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	// Arrays look for an element equal to the value
+	if len(args) == 2 {
+		if array, ok := args[0].(*ArrayValue); ok {
+			return NUMBER(float64(indexOf(array, args[1])))
+		}
+	}
+
+	strs := stringArguments("index_of", args, 2)
+
+	// Counted in characters, like indexing, rather than bytes
+	index := strings.Index(strs[0], strs[1])
+	if index < 0 {
+		return NUMBER(-1)
+	}
+	return NUMBER(float64(utf8.RuneCountInString(strs[0][:index])))
+}
+
+func NATIVE_JOIN_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, "join() expects exactly 2 arguments (array, separator)")
+	}
+
+	arrayValue, ok := args[0].(*ArrayValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("join() expects an array, got '%s'", args[0].Type()))
+	}
+
+	separator, ok := args[1].(*StringValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("join() expects a string separator, got '%s'", args[1].Type()))
+	}
+
+	parts := make([]string, len(arrayValue.Elements))
+	size := len(separator.Value) * max(len(parts)-1, 0)
+	for i, element := range arrayValue.Elements {
+		str, ok := element.(*StringValue)
+		if !ok {
+			errors.RaiseRuntime(nil, fmt.Sprintf("join() expects an array of strings, got '%s' at index %d", element.Type(), i))
+		}
+		parts[i] = str.Value
+		size += len(str.Value)
+	}
+	env.Interpreter().Allocate("join", size, 1)
+
+	return &StringValue{Value: strings.Join(parts, separator.Value)}
+}
+
+func NATIVE_REPLACE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	strs := stringArguments("replace", args, 3)
+	if growth := len(strs[2]) - len(strs[1]); growth > 0 {
+		env.Interpreter().Allocate("replace", len(strs[0])+strings.Count(strs[0], strs[1])*growth, 1)
+	}
+
+	// Every occurrence is replaced
+	return &StringValue{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+}
+
+func NATIVE_STARTS_WITH_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	strs := stringArguments("starts_with", args, 2)
+	return BOOLEAN(strings.HasPrefix(strs[0], strs[1]))
+}
+
+func NATIVE_ENDS_WITH_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	strs := stringArguments("ends_with", args, 2)
+	return BOOLEAN(strings.HasSuffix(strs[0], strs[1]))
+}
+
+func NATIVE_REPEAT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, "repeat() expects exactly 2 arguments (string, count)")
+	}
+
+	str := stringArgument("repeat", args[:1], 1)
+	count := integerArgument("repeat", args[1])
+	if count < 0 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("repeat() count cannot be negative, got %d", count))
+	}
+	if len(str.Value) > 0 {
+		env.Interpreter().Allocate("repeat", count, len(str.Value))
+	}
+
+	return &StringValue{Value: strings.Repeat(str.Value, count)}
+}
+
+func NATIVE_CHARS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	str := stringArgument("chars", args, 1)
+	env.Interpreter().Allocate("chars", len(str.Value), valueSize)
+
+	var elements []RuntimeValue
+	for _, r := range str.Value {
+		elements = append(elements, &StringValue{Value: string(r)})
+	}
+
+	return ARRAY(elements)
+}
+
+// stringArgument checks that a native received exactly count arguments and
+// that the first one is a string, which it returns.
+func stringArgument(name string, args []RuntimeValue, count int) *StringValue {
+	if len(args) != count {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects exactly %d argument(s) but got %d", name, count, len(args)))
+		return nil
+	}
+
+	str, ok := args[0].(*StringValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects a string, got '%s'", name, args[0].Type()))
+		return nil
+	}
+
+	return str
+}
+
+// stringArguments checks that a native received exactly count arguments, all
+// of them strings, and returns their values.
+func stringArguments(name string, args []RuntimeValue, count int) []string {
+	if len(args) != count {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects exactly %d argument(s) but got %d", name, count, len(args)))
+	}
+
+	strs := make([]string, count)
+	for i, arg := range args {
+		str, ok := arg.(*StringValue)
+		if !ok {
+			errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects a string, got '%s'", name, arg.Type()))
+		}
+		strs[i] = str.Value
+	}
+
+	return strs
+}
+
+// integerArgument checks that a native's argument is a whole number and
+// returns it.
+func integerArgument(name string, arg RuntimeValue) int {
+	number, ok := arg.(*NumberValue)
+	if !ok || number.Value != math.Trunc(number.Value) {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects an integer, got %v", name, arg))
+	}
+
+	return int(number.Value)
 }
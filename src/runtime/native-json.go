@@ -0,0 +1,475 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/caelondev/mutex/src/errors"
+)
+
+// NATIVE_JSON_PARSE_FUNCTION converts JSON text to Mutex values: objects
+// become maps that keep the order of their keys, and every number becomes a
+// number.
+func NATIVE_JSON_PARSE_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	str := stringArgument("json_parse", args, 1)
+
+	parser := &jsonParser{source: str.Value, line: 1, column: 1}
+	parser.skipWhitespace()
+	value := parser.value()
+
+	parser.skipWhitespace()
+	if parser.position < len(parser.source) {
+		parser.fail("Unexpected %s after the JSON value", parser.describe())
+	}
+
+	return value
+}
+
+// NATIVE_JSON_STRINGIFY_FUNCTION converts a value to JSON text. The optional
+// indent, a number of spaces or a string, spreads arrays and maps over
+// several lines.
+func NATIVE_JSON_STRINGIFY_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 && len(args) != 2 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("json_stringify() expects 1 or 2 arguments but got %d", len(args)))
+	}
+
+	encoder := &jsonEncoder{}
+	if len(args) == 2 {
+		switch indent := args[1].(type) {
+		case *StringValue:
+			env.Interpreter().Allocate("json_stringify", len(indent.Value), 1)
+			encoder.indent = indent.Value
+		case *NumberValue:
+			spaces := integerArgument("json_stringify", indent)
+			if spaces < 0 {
+				errors.RaiseRuntime(nil, fmt.Sprintf("json_stringify() expects a non-negative indent, got %d", spaces))
+			}
+			env.Interpreter().Allocate("json_stringify", spaces, 1)
+			encoder.indent = strings.Repeat(" ", spaces)
+		default:
+			errors.RaiseRuntime(nil, fmt.Sprintf("json_stringify() expects the indent to be a number or string, got '%s'", args[1].Type()))
+		}
+	}
+
+	encoder.value(args[0], 0)
+	return &StringValue{Value: encoder.output.String()}
+}
+
+// Parsing ---
+
+// maxJSONDepth is how deeply arrays and objects may nest in parsed JSON, so
+// deep input fails before the parser's recursion exhausts the Go stack.
+const maxJSONDepth = 10000
+
+type jsonParser struct {
+	source   string
+	position int // Byte offset of the next character
+	line     int
+	column   int // Counted in characters, like the scanner does
+	depth    int // Arrays and objects the parser is inside
+}
+
+// fail raises a runtime error pointing at the parser's current position.
+func (p *jsonParser) fail(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	errors.RaiseRuntime(nil, fmt.Sprintf("json_parse() failed: %s at line %d, column %d", message, p.line, p.column))
+}
+
+// describe names the next character for an error message.
+func (p *jsonParser) describe() string {
+	if p.position >= len(p.source) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRuneInString(p.source[p.position:])
+	return fmt.Sprintf("character %q", r)
+}
+
+func (p *jsonParser) peek() byte {
+	if p.position >= len(p.source) {
+		return 0
+	}
+	return p.source[p.position]
+}
+
+func (p *jsonParser) advance() rune {
+	r, size := utf8.DecodeRuneInString(p.source[p.position:])
+	p.position += size
+
+	if r == '\n' {
+		p.line++
+		p.column = 1
+	} else {
+		p.column++
+	}
+	return r
+}
+
+func (p *jsonParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail("Expected '%c' but found %s", c, p.describe())
+	}
+	p.advance()
+}
+
+func (p *jsonParser) skipWhitespace() {
+	for p.position < len(p.source) {
+		switch p.peek() {
+		case ' ', '\t', '\n', '\r':
+			p.advance()
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) value() RuntimeValue {
+	if p.position >= len(p.source) {
+		p.fail("Unexpected end of input")
+	}
+
+	switch c := p.peek(); {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		return &StringValue{Value: p.string()}
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	case strings.HasPrefix(p.source[p.position:], "true"):
+		p.literal("true")
+		return BOOLEAN(true)
+	case strings.HasPrefix(p.source[p.position:], "false"):
+		p.literal("false")
+		return BOOLEAN(false)
+	case strings.HasPrefix(p.source[p.position:], "null"):
+		p.literal("null")
+		return NIL()
+	}
+
+	p.fail("Unexpected %s", p.describe())
+	return nil
+}
+
+// nest enters an array or object, failing past maxJSONDepth.
+func (p *jsonParser) nest() {
+	p.depth++
+	if p.depth > maxJSONDepth {
+		p.fail("Arrays and objects nested more than %d deep", maxJSONDepth)
+	}
+}
+
+func (p *jsonParser) literal(word string) {
+	for range word {
+		p.advance()
+	}
+}
+
+func (p *jsonParser) object() RuntimeValue {
+	object := MAP()
+	p.nest()
+	defer func() { p.depth-- }()
+
+	p.expect('{')
+	p.skipWhitespace()
+
+	if p.peek() == '}' {
+		p.advance()
+		return object
+	}
+
+	for {
+		if p.peek() != '"' {
+			p.fail("Expected a string key but found %s", p.describe())
+		}
+		key := p.string()
+
+		p.skipWhitespace()
+		p.expect(':')
+		p.skipWhitespace()
+		object.Set(key, p.value())
+		p.skipWhitespace()
+
+		if p.peek() != ',' {
+			break
+		}
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	p.expect('}')
+	return object
+}
+
+func (p *jsonParser) array() RuntimeValue {
+	elements := []RuntimeValue{}
+	p.nest()
+	defer func() { p.depth-- }()
+
+	p.expect('[')
+	p.skipWhitespace()
+
+	if p.peek() == ']' {
+		p.advance()
+		return ARRAY(elements)
+	}
+
+	for {
+		elements = append(elements, p.value())
+		p.skipWhitespace()
+
+		if p.peek() != ',' {
+			break
+		}
+		p.advance()
+		p.skipWhitespace()
+	}
+
+	p.expect(']')
+	return ARRAY(elements)
+}
+
+func (p *jsonParser) string() string {
+	var builder strings.Builder
+	p.expect('"')
+
+	for {
+		if p.position >= len(p.source) {
+			p.fail("Unterminated string")
+		}
+
+		switch c := p.peek(); {
+		case c == '"':
+			p.advance()
+			return builder.String()
+		case c < 0x20:
+			p.fail("Control character %q in string", rune(c))
+		case c == '\\':
+			builder.WriteRune(p.escape())
+		default:
+			builder.WriteRune(p.advance())
+		}
+	}
+}
+
+// escape decodes the escape sequence at the parser's position, joining a
+// UTF-16 surrogate pair into the one character it encodes.
+func (p *jsonParser) escape() rune {
+	p.advance()
+	if p.position >= len(p.source) {
+		p.fail("Unterminated string")
+	}
+
+	switch c := p.peek(); c {
+	case '"', '\\', '/':
+		p.advance()
+		return rune(c)
+	case 'b':
+		p.advance()
+		return '\b'
+	case 'f':
+		p.advance()
+		return '\f'
+	case 'n':
+		p.advance()
+		return '\n'
+	case 'r':
+		p.advance()
+		return '\r'
+	case 't':
+		p.advance()
+		return '\t'
+	case 'u':
+		p.advance()
+		r := p.hex()
+		if r < 0xD800 || r > 0xDBFF || !strings.HasPrefix(p.source[p.position:], `\u`) {
+			return r
+		}
+
+		p.literal(`\u`)
+		if low := p.hex(); low >= 0xDC00 && low <= 0xDFFF {
+			return 0x10000 + (r-0xD800)<<10 + (low - 0xDC00)
+		}
+		return utf8.RuneError
+	}
+
+	p.fail("Invalid escape sequence '\\%c'", p.peek())
+	return 0
+}
+
+func (p *jsonParser) hex() rune {
+	if p.position+4 > len(p.source) {
+		p.fail("Expected 4 hexadecimal digits after '\\u'")
+	}
+
+	value, err := strconv.ParseUint(p.source[p.position:p.position+4], 16, 32)
+	if err != nil {
+		p.fail("Expected 4 hexadecimal digits after '\\u'")
+	}
+
+	p.literal("0000")
+	return rune(value)
+}
+
+func (p *jsonParser) number() RuntimeValue {
+	start := p.position
+	startLine, startColumn := p.line, p.column
+
+	if p.peek() == '-' {
+		p.advance()
+	}
+
+	// No leading zeros, as JSON does not allow them ---
+	if p.peek() == '0' {
+		p.advance()
+	} else {
+		p.digits()
+	}
+
+	if p.peek() == '.' {
+		p.advance()
+		p.digits()
+	}
+
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.advance()
+		if c := p.peek(); c == '+' || c == '-' {
+			p.advance()
+		}
+		p.digits()
+	}
+
+	value, err := strconv.ParseFloat(p.source[start:p.position], 64)
+	if err != nil {
+		p.line, p.column = startLine, startColumn
+		p.fail("Number %s is out of range", p.source[start:p.position])
+	}
+
+	return NUMBER(value)
+}
+
+func (p *jsonParser) digits() {
+	if c := p.peek(); c < '0' || c > '9' {
+		p.fail("Expected a digit but found %s", p.describe())
+	}
+
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.advance()
+	}
+}
+
+// Encoding ---
+
+type jsonEncoder struct {
+	output strings.Builder
+	indent string
+	active []RuntimeValue // Arrays and maps being encoded, to catch cycles
+}
+
+func (e *jsonEncoder) value(value RuntimeValue, depth int) {
+	switch v := value.(type) {
+	case *NilValue:
+		e.output.WriteString("null")
+	case *BooleanValue:
+		e.output.WriteString(strconv.FormatBool(v.Value))
+	case *NumberValue:
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			errors.RaiseRuntime(nil, fmt.Sprintf("json_stringify() cannot encode the number %v", v.Value))
+		}
+		e.output.WriteString(strconv.FormatFloat(v.Value+0, 'f', -1, 64)) // +0 turns -0 into 0
+	case *StringValue:
+		e.string(v.Value)
+	case *ArrayValue:
+		e.enter(v)
+		e.output.WriteByte('[')
+		for i, element := range v.Elements {
+			e.separator(i, depth+1)
+			e.value(element, depth+1)
+		}
+		e.close(len(v.Elements), depth, ']')
+		e.leave()
+	case *MapValue:
+		e.enter(v)
+		e.output.WriteByte('{')
+		for i, key := range v.Keys {
+			e.separator(i, depth+1)
+			e.string(key)
+			e.output.WriteByte(':')
+			if e.indent != "" {
+				e.output.WriteByte(' ')
+			}
+			e.value(v.Entries[key], depth+1)
+		}
+		e.close(len(v.Keys), depth, '}')
+		e.leave()
+	default:
+		errors.RaiseRuntime(nil, fmt.Sprintf("json_stringify() cannot encode a value of type '%s'", value.Type()))
+	}
+}
+
+// enter marks container as being encoded, raising an error if it already
+// is, as the value would then contain itself.
+func (e *jsonEncoder) enter(container RuntimeValue) {
+	for _, active := range e.active {
+		if active == container {
+			errors.RaiseRuntime(nil, "json_stringify() cannot encode a value that contains itself")
+		}
+	}
+	e.active = append(e.active, container)
+}
+
+func (e *jsonEncoder) leave() {
+	e.active = e.active[:len(e.active)-1]
+}
+
+// separator starts the i-th element of an array or map.
+func (e *jsonEncoder) separator(i int, depth int) {
+	if i > 0 {
+		e.output.WriteByte(',')
+	}
+	e.newline(depth)
+}
+
+// close ends an array or map of count elements.
+func (e *jsonEncoder) close(count int, depth int, bracket byte) {
+	if count > 0 {
+		e.newline(depth)
+	}
+	e.output.WriteByte(bracket)
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.output.WriteByte('\n')
+	e.output.WriteString(strings.Repeat(e.indent, depth))
+}
+
+func (e *jsonEncoder) string(str string) {
+	e.output.WriteByte('"')
+	for _, r := range str {
+		switch r {
+		case '"':
+			e.output.WriteString(`\"`)
+		case '\\':
+			e.output.WriteString(`\\`)
+		case '\n':
+			e.output.WriteString(`\n`)
+		case '\r':
+			e.output.WriteString(`\r`)
+		case '\t':
+			e.output.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&e.output, `\u%04x`, r)
+			} else {
+				e.output.WriteRune(r)
+			}
+		}
+	}
+	e.output.WriteByte('"')
+}
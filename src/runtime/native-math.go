@@ -0,0 +1,187 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/caelondev/mutex/src/errors"
+)
+
+func NATIVE_ABS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	return NUMBER(math.Abs(numberArguments("abs", args, 1)[0]))
+}
+
+func NATIVE_FLOOR_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	return NUMBER(math.Floor(numberArguments("floor", args, 1)[0]))
+}
+
+func NATIVE_CEIL_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	return NUMBER(math.Ceil(numberArguments("ceil", args, 1)[0]))
+}
+
+func NATIVE_ROUND_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	// Halves round away from zero
+	return NUMBER(math.Round(numberArguments("round", args, 1)[0]))
+}
+
+func NATIVE_SQRT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	x := numberArguments("sqrt", args, 1)[0]
+	if x < 0 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("sqrt() expects a non-negative number, got %v", x))
+	}
+	return NUMBER(math.Sqrt(x))
+}
+
+func NATIVE_POW_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	numbers := numberArguments("pow", args, 2)
+	return NUMBER(math.Pow(numbers[0], numbers[1]))
+}
+
+func NATIVE_MIN_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) == 0 {
+		errors.RaiseRuntime(nil, "min() expects at least 1 argument")
+	}
+
+	numbers := numberArguments("min", args, len(args))
+	result := numbers[0]
+	for _, number := range numbers[1:] {
+		result = math.Min(result, number)
+	}
+	return NUMBER(result)
+}
+
+func NATIVE_MAX_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) == 0 {
+		errors.RaiseRuntime(nil, "max() expects at least 1 argument")
+	}
+
+	numbers := numberArguments("max", args, len(args))
+	result := numbers[0]
+	for _, number := range numbers[1:] {
+		result = math.Max(result, number)
+	}
+	return NUMBER(result)
+}
+
+func NATIVE_LOG_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 && len(args) != 2 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("log() expects 1 or 2 arguments (number, base) but got %d", len(args)))
+	}
+
+	numbers := numberArguments("log", args, len(args))
+	if numbers[0] <= 0 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("log() expects a positive number, got %v", numbers[0]))
+	}
+
+	// Natural logarithm unless a base is given
+	if len(numbers) == 1 {
+		return NUMBER(math.Log(numbers[0]))
+	}
+
+	base := numbers[1]
+	if base <= 0 || base == 1 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("log() expects a positive base other than 1, got %v", base))
+	}
+	return NUMBER(math.Log(numbers[0]) / math.Log(base))
+}
+
+func NATIVE_SIN_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	return NUMBER(math.Sin(numberArguments("sin", args, 1)[0]))
+}
+
+func NATIVE_COS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	return NUMBER(math.Cos(numberArguments("cos", args, 1)[0]))
+}
+
+func NATIVE_TAN_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	return NUMBER(math.Tan(numberArguments("tan", args, 1)[0]))
+}
+
+func NATIVE_ASIN_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	x := numberArguments("asin", args, 1)[0]
+	if x < -1 || x > 1 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("asin() expects a number between -1 and 1, got %v", x))
+	}
+	return NUMBER(math.Asin(x))
+}
+
+func NATIVE_ACOS_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	x := numberArguments("acos", args, 1)[0]
+	if x < -1 || x > 1 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("acos() expects a number between -1 and 1, got %v", x))
+	}
+	return NUMBER(math.Acos(x))
+}
+
+func NATIVE_ATAN_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	return NUMBER(math.Atan(numberArguments("atan", args, 1)[0]))
+}
+
+func NATIVE_ATAN2_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	numbers := numberArguments("atan2", args, 2)
+	return NUMBER(math.Atan2(numbers[0], numbers[1]))
+}
+
+// NATIVE_DIV_FUNCTION divides and rounds down, so div(-7, 2) is -4 and
+// a - div(a, b) * b always has the sign of b.
+func NATIVE_DIV_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	numbers := numberArguments("div", args, 2)
+	if numbers[1] == 0 {
+		errors.RaiseRuntime(nil, "Division by zero")
+	}
+	return NUMBER(math.Floor(numbers[0] / numbers[1]))
+}
+
+func NATIVE_RANDOM_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	numberArguments("random", args, 0)
+
+	// In [0, 1)
+	return NUMBER(env.Interpreter().Random.Float64())
+}
+
+func NATIVE_RANDOM_INT_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 2 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("random_int() expects exactly 2 arguments (min, max) but got %d", len(args)))
+	}
+
+	low := integerArgument("random_int", args[0])
+	high := integerArgument("random_int", args[1])
+	if low > high {
+		errors.RaiseRuntime(nil, fmt.Sprintf("random_int() expects min to be at most max, got %d and %d", low, high))
+	}
+
+	// Both bounds are included
+	return NUMBER(float64(low + env.Interpreter().Random.IntN(high-low+1)))
+}
+
+// NATIVE_SEED_FUNCTION restarts the random number generator from seed, so
+// the numbers random() and random_int() produce after it are always the same.
+func NATIVE_SEED_FUNCTION(args []RuntimeValue, env Environment) RuntimeValue {
+	if len(args) != 1 {
+		errors.RaiseRuntime(nil, fmt.Sprintf("seed() expects exactly 1 argument but got %d", len(args)))
+	}
+
+	seed := uint64(integerArgument("seed", args[0]))
+	env.Interpreter().Random = rand.New(rand.NewPCG(seed, seed))
+	return NIL()
+}
+
+// numberArguments checks that a native received exactly count arguments, all
+// of them numbers, and returns their values.
+func numberArguments(name string, args []RuntimeValue, count int) []float64 {
+	if len(args) != count {
+		errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects exactly %d argument(s) but got %d", name, count, len(args)))
+	}
+
+	numbers := make([]float64, count)
+	for i, arg := range args {
+		number, ok := arg.(*NumberValue)
+		if !ok {
+			errors.RaiseRuntime(nil, fmt.Sprintf("%s() expects a number, got '%s'", name, arg.Type()))
+		}
+		numbers[i] = number.Value
+	}
+
+	return numbers
+}
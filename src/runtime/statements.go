@@ -1,18 +1,22 @@
 package runtime
 
 import (
+	"fmt"
+	"slices"
+
+	"github.com/caelondev/mutex/src/errors"
 	"github.com/caelondev/mutex/src/frontend/ast"
 )
 
 func evaluateBlockStatement(block *ast.BlockStatement, env Environment) RuntimeValue {
-	blockEnv := NewEnvironment(env)
+	blockEnv := newScope(env, block.Slots)
 	var lastEvaluated RuntimeValue = NIL()
 
 	for _, statement := range block.Body {
-		lastEvaluated = EvaluateStatement(statement, blockEnv)
+		lastEvaluated = evaluateStatement(statement, blockEnv)
 		
-		// If we hit a return statement, bubble it up immediately
-		if _, isReturn := lastEvaluated.(*ReturnValue); isReturn {
+		// If we hit a return, break or continue statement, bubble it up immediately
+		if _, isSignal := lastEvaluated.(ControlSignal); isSignal {
 			return lastEvaluated
 		}
 	}
@@ -24,21 +28,22 @@ func evaluateVariableDeclarationStatement(stmt *ast.VariableDeclarationStatement
 	var value RuntimeValue
 
 	if stmt.Value != nil {
-		value = EvaluateExpression(stmt.Value, env)
+		value = evaluateExpression(stmt.Value, env)
 	} else {
 		value = NIL()
 	}
 
-	return env.DeclareVariable(stmt.Identifier, value, !stmt.IsMutable)
+	declare(env, stmt.Binding, stmt.Identifier, value, !stmt.IsMutable)
+	return NIL()
 }
 
 func evaluateIfStatement(stmt *ast.IfStatement, env Environment) RuntimeValue {
-	condition := EvaluateExpression(stmt.Condition, env)
+	condition := evaluateExpression(stmt.Condition, env)
 
-	if isTruthy(condition) {
-		return EvaluateStatement(stmt.Consequent, env)
+	if IsTruthy(condition) {
+		return evaluateStatement(stmt.Consequent, env)
 	} else if stmt.Alternate != nil {
-		return EvaluateStatement(stmt.Alternate, env)
+		return evaluateStatement(stmt.Alternate, env)
 	}
 
 	return NIL()
@@ -46,41 +51,156 @@ func evaluateIfStatement(stmt *ast.IfStatement, env Environment) RuntimeValue {
 
 func evaluateWhileStatement(stmt *ast.WhileStatement, env Environment) RuntimeValue {
 	for {
-		condition := EvaluateExpression(stmt.Condition, env)
+		condition := evaluateExpression(stmt.Condition, env)
 
-		if !isTruthy(condition) {
+		if !IsTruthy(condition) {
 			break
 		}
 
-		EvaluateStatement(stmt.Body, env)
+		result := evaluateStatement(stmt.Body, env)
+
+		if exit, signal := loopSignal(result, stmt.Label); exit {
+			return signal
+		}
+
+		env.Interpreter().Step()
 	}
 
 	return NIL()
 }
 
 func evaluateForStatement(stmt *ast.ForStatement, env Environment) RuntimeValue {
-	loopEnv := NewEnvironment(env)
+	loopEnv := newScope(env, 1)
 
-	EvaluateStatement(stmt.Initializer, loopEnv)
+	evaluateStatement(stmt.Initializer, loopEnv)
 
 	for {
-		condition := EvaluateExpression(stmt.Condition, loopEnv)
+		condition := evaluateExpression(stmt.Condition, loopEnv)
 
-		if !isTruthy(condition) {
+		if !IsTruthy(condition) {
 			break
 		}
 
-		EvaluateStatement(stmt.Body, loopEnv)
-		EvaluateExpression(stmt.Increment, loopEnv)
+		result := evaluateStatement(stmt.Body, loopEnv)
+
+		if exit, signal := loopSignal(result, stmt.Label); exit {
+			return signal
+		}
+
+		evaluateExpression(stmt.Increment, loopEnv)
+		env.Interpreter().Step()
+	}
+
+	return NIL()
+}
+
+func evaluateForInStatement(stmt *ast.ForInStatement, env Environment) RuntimeValue {
+	iterable := evaluateExpression(stmt.Iterable, env)
+
+	for _, item := range IterationItems(iterable) {
+		// Each iteration gets a fresh binding, so closures capture their own item ---
+		loopEnv := newScope(env, 1)
+		loopEnv.SetSlot(0, 0, item)
+
+		result := evaluateStatement(stmt.Body, loopEnv)
+
+		if exit, signal := loopSignal(result, stmt.Label); exit {
+			return signal
+		}
+
+		env.Interpreter().Step()
 	}
 
 	return NIL()
 }
 
+// IterationItems returns what a for-in loop over iterable visits: the
+// elements of an array or the keys of a map. The items are a snapshot, so
+// mutating the collection inside the loop body is safe.
+func IterationItems(iterable RuntimeValue) []RuntimeValue {
+	var items []RuntimeValue
+
+	switch collection := iterable.(type) {
+	case *ArrayValue:
+		items = slices.Clone(collection.Elements)
+	case *MapValue:
+		for _, key := range collection.Keys {
+			items = append(items, &StringValue{Value: key})
+		}
+	default:
+		errors.RaiseRuntime(nil, fmt.Sprintf("Cannot iterate over type '%s', expected array or map", iterable.Type()))
+	}
+
+	return items
+}
+
+// Superclass checks that value, found under superclassName, can be inherited
+// from by the class className.
+func Superclass(className, superclassName string, value RuntimeValue) *ClassValue {
+	class, ok := value.(*ClassValue)
+	if !ok {
+		errors.RaiseRuntime(nil, fmt.Sprintf("Class '%s' cannot inherit from '%s' of type '%s'", className, superclassName, value.Type()))
+	}
+
+	return class
+}
+
+// loopSignal decides what the loop labelled label does after one pass over
+// its body produced result. When exit is true the loop must stop and return
+// value: NIL for a break aimed at this loop, otherwise the signal itself so it
+// keeps unwinding (a return, or a break/continue aimed at an outer loop).
+func loopSignal(result RuntimeValue, label string) (exit bool, value RuntimeValue) {
+	switch signal := result.(type) {
+	case *BreakValue:
+		if targetsLoop(signal.Label, label) {
+			return true, NIL()
+		}
+		return true, signal
+	case *ContinueValue:
+		if targetsLoop(signal.Label, label) {
+			return false, nil
+		}
+		return true, signal
+	case *ReturnValue:
+		return true, signal
+	}
+
+	return false, nil
+}
+
+// targetsLoop reports whether a break/continue carrying signalLabel is meant
+// for the loop labelled loopLabel. Unlabelled signals target the innermost loop.
+func targetsLoop(signalLabel, loopLabel string) bool {
+	return signalLabel == "" || signalLabel == loopLabel
+}
+
 func evaluateFunctionDeclaration(stmt *ast.FunctionDeclaration, env Environment) RuntimeValue {
 	functionValue := FUNCTION(stmt.Name, stmt.Parameters, stmt.Body, env)
 
-	env.DeclareVariable(stmt.Name, functionValue, true)
+	declare(env, stmt.Binding, stmt.Name, functionValue, true)
+
+	return NIL()
+}
+
+func evaluateClassDeclaration(stmt *ast.ClassDeclaration, env Environment) RuntimeValue {
+	var superclass *ClassValue
+	methodEnv := env
+
+	if stmt.Superclass != "" {
+		superclass = Superclass(stmt.Name, stmt.Superclass, lookup(env, stmt.SuperclassBinding, stmt.Superclass))
+
+		// Methods reach the superclass through 'super' ---
+		superEnv := newScope(env, 1)
+		superEnv.SetSlot(0, 0, superclass)
+		methodEnv = superEnv
+	}
+
+	methods := map[string]Method{}
+	for _, method := range stmt.Methods {
+		methods[method.Name] = FUNCTION(method.Name, method.Parameters, method.Body, methodEnv)
+	}
+
+	declare(env, stmt.Binding, stmt.Name, CLASS(stmt.Name, superclass, methods), true)
 
 	return NIL()
 }
@@ -89,10 +209,135 @@ func evaluateReturnStatement(stmt *ast.ReturnStatement, env Environment) Runtime
 	var value RuntimeValue
 
 	if stmt.Value != nil {
-		value = EvaluateExpression(stmt.Value, env)
+		value = evaluateExpression(stmt.Value, env)
 	} else {
 		value = NIL()
 	}
 
 	return &ReturnValue{Value: value}
 }
+
+func evaluateImportStatement(stmt *ast.ImportStatement, env Environment) RuntimeValue {
+	declare(env, stmt.Binding, stmt.Name, Import(stmt.Path, env), true)
+	return NIL()
+}
+
+// Import loads the module at path through the host's Importer. Relative
+// paths start from the file env's code was read from.
+func Import(path string, env Environment) *ModuleValue {
+	importer := env.Interpreter().Importer
+	if importer == nil {
+		errors.RaiseRuntime(nil, "Modules cannot be imported here")
+	}
+
+	module, err := importer.Import(path, env.Module())
+	if err != nil {
+		panic(err) // Carries on as if the module's own code had raised it ---
+	}
+	return module
+}
+
+func evaluateThrowStatement(stmt *ast.ThrowStatement, env Environment) RuntimeValue {
+	Throw(evaluateExpression(stmt.Value, env))
+	return NIL()
+}
+
+func evaluateTryStatement(stmt *ast.TryStatement, env Environment) (result RuntimeValue) {
+	// The finally block runs however the try statement ends. A return, break
+	// or continue inside it wins over the error or signal being passed on ---
+	if stmt.Finally != nil {
+		defer func() {
+			r := recover()
+
+			// A script past its limits must stop, so it gets no chance to run more ---
+			if _, isLimit := r.(*errors.LimitError); isLimit {
+				panic(r)
+			}
+
+			if signal, isSignal := evaluateStatement(stmt.Finally, env).(ControlSignal); isSignal {
+				result = signal
+				return
+			}
+			if r != nil {
+				panic(r)
+			}
+		}()
+	}
+
+	if stmt.Catch == nil {
+		return evaluateStatement(stmt.Body, env)
+	}
+
+	result, thrown := catchError(func() RuntimeValue {
+		return evaluateStatement(stmt.Body, env)
+	})
+	if thrown == nil {
+		return result
+	}
+
+	catchEnv := newScope(env, 1)
+	catchEnv.SetSlot(0, 0, thrown)
+	return evaluateStatement(stmt.Catch, catchEnv)
+}
+
+// catchError runs body and returns its result, or the value a catch clause
+// binds for the error raised while it ran.
+func catchError(body func() RuntimeValue) (result RuntimeValue, thrown RuntimeValue) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		err, ok := r.(*errors.RuntimeError)
+		if !ok {
+			panic(r)
+		}
+		thrown = Caught(err)
+	}()
+
+	return body(), nil
+}
+
+// Throw raises value so the nearest enclosing catch clause receives it. An
+// error value that was raised before is raised again as it was, keeping the
+// place it first happened.
+func Throw(value RuntimeValue) {
+	errorValue, isError := value.(*ErrorValue)
+	if isError && errorValue.Err != nil {
+		panic(errorValue.Err)
+	}
+
+	err := errors.NewRuntimeError(nil, "Uncaught "+value.String())
+	err.Value = value
+	if isError {
+		errorValue.Err = err
+	}
+	panic(err)
+}
+
+// Caught returns the value a catch clause binds for err: the thrown value,
+// or an error value describing a failure of the interpreter itself.
+func Caught(err *errors.RuntimeError) RuntimeValue {
+	if value, ok := err.Value.(RuntimeValue); ok {
+		return value
+	}
+
+	value := &ErrorValue{Kind: "RuntimeError", Message: err.Message, Err: err}
+	err.Value = value
+	return value
+}
+
+// errorStack lists the calls the error e describes passed through, innermost
+// first, empty while it has not been thrown.
+func errorStack(e *ErrorValue) RuntimeValue {
+	frames := []RuntimeValue{}
+	if e.Err == nil {
+		return ARRAY(frames)
+	}
+
+	for _, frame := range e.Err.Trace {
+		frames = append(frames, &StringValue{Value: frame.String()})
+	}
+	return ARRAY(frames)
+}
@@ -2,8 +2,10 @@ package runtime
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/caelondev/mutex/src/errors"
 	"github.com/caelondev/mutex/src/frontend/ast"
 )
 
@@ -15,8 +17,12 @@ const (
 	NUMBER_VALUE ValueTypes = "number"
 	STRING_VALUE ValueTypes = "string"
 	ARRAY_VALUE ValueTypes = "array"
+	MAP_VALUE ValueTypes = "map"
 	FUNCTION_VALUE        ValueTypes = "function"
 	NATIVE_FUNCTION_VALUE ValueTypes = "native_function"
+	CLASS_VALUE ValueTypes = "class"
+	MODULE_VALUE ValueTypes = "module"
+	ERROR_VALUE ValueTypes = "error"
 )
 
 type RuntimeValue interface {
@@ -85,10 +91,30 @@ func (f *FunctionValue) String() string {
 	return fmt.Sprintf("[ ...function '%s'... ]", f.Name)
 }
 
+func (f *FunctionValue) Bind(instance *InstanceValue) RuntimeValue {
+	return bindMethod(f, instance)
+}
+
+// Method is a function value that can be stored on a class. Binding it to an
+// instance yields the function that runs with 'this' set to that instance.
+type Method interface {
+	RuntimeValue
+	Bind(instance *InstanceValue) RuntimeValue
+}
+
+// Callable is implemented by function values that are not evaluated by the
+// tree walker, such as functions compiled for the bytecode VM. Natives and
+// classes call them through Invoke.
+type Callable interface {
+	RuntimeValue
+	Invoke(args []RuntimeValue) RuntimeValue
+}
+
 
 type NativeFunctionValue struct {
-	Name string
-	Call func(args []RuntimeValue, env Environment) RuntimeValue
+	Name     string
+	Call     func(args []RuntimeValue, env Environment) RuntimeValue
+	Receiver RuntimeValue // Value a built-in method was taken from, nil for other natives
 }
 
 func (n *NativeFunctionValue) Type() ValueTypes {
@@ -100,6 +126,87 @@ func (n *NativeFunctionValue) String() string {
 }
 
 
+type ClassValue struct {
+	Name       string
+	Superclass *ClassValue // nil when the class does not inherit
+	Methods    map[string]Method
+}
+
+func (c *ClassValue) Type() ValueTypes {
+	return CLASS_VALUE
+}
+
+func (c *ClassValue) String() string {
+	return fmt.Sprintf("[ ...class '%s'... ]", c.Name)
+}
+
+// FindMethod looks name up on the class and then along its superclass chain.
+func (c *ClassValue) FindMethod(name string) (Method, bool) {
+	for class := c; class != nil; class = class.Superclass {
+		if method, ok := class.Methods[name]; ok {
+			return method, true
+		}
+	}
+	return nil, false
+}
+
+// InstanceValue is an object created by calling a class. Its type is the
+// name of its class.
+type InstanceValue struct {
+	Class  *ClassValue
+	Fields *MapValue
+}
+
+func (i *InstanceValue) Type() ValueTypes {
+	return ValueTypes(i.Class.Name)
+}
+
+func (i *InstanceValue) String() string {
+	return format(i, nil)
+}
+
+// ModuleValue is the namespace an import statement binds. Reading a member
+// reads the exported variable itself, so later assignments made inside the
+// module are visible to importers.
+type ModuleValue struct {
+	Name    string
+	Path    string
+	Exports []string
+	env     Environment
+}
+
+func (m *ModuleValue) Type() ValueTypes {
+	return MODULE_VALUE
+}
+
+func (m *ModuleValue) String() string {
+	return fmt.Sprintf("[ ...module '%s'... ]", m.Name)
+}
+
+// ErrorValue describes a failure: one a catch clause caught, or one made by
+// error() to be thrown. Err is the error it was raised as, nil until then.
+type ErrorValue struct {
+	Kind    string
+	Message string
+	Err     *errors.RuntimeError
+}
+
+func (e *ErrorValue) Type() ValueTypes {
+	return ERROR_VALUE
+}
+
+func (e *ErrorValue) String() string {
+	return e.Kind + ": " + e.Message
+}
+
+// ControlSignal is implemented by the values produced by return, break and
+// continue. Statements stop executing as soon as they see one and hand it to
+// their enclosing statement until the loop or call it targets handles it.
+type ControlSignal interface {
+	RuntimeValue
+	controlSignal()
+}
+
 type ReturnValue struct {
 	Value RuntimeValue
 }
@@ -112,6 +219,36 @@ func (r *ReturnValue) String() string {
 	return r.Value.String()
 }
 
+func (r *ReturnValue) controlSignal() {}
+
+type BreakValue struct {
+	Label string // Empty when breaking out of the innermost loop
+}
+
+func (b *BreakValue) Type() ValueTypes {
+	return "break"
+}
+
+func (b *BreakValue) String() string {
+	return "break"
+}
+
+func (b *BreakValue) controlSignal() {}
+
+type ContinueValue struct {
+	Label string // Empty when continuing the innermost loop
+}
+
+func (c *ContinueValue) Type() ValueTypes {
+	return "continue"
+}
+
+func (c *ContinueValue) String() string {
+	return "continue"
+}
+
+func (c *ContinueValue) controlSignal() {}
+
 type ArrayValue struct {
 	Elements []RuntimeValue
 }
@@ -121,15 +258,87 @@ func (a *ArrayValue) Type() ValueTypes {
 }
 
 func (a *ArrayValue) String() string {
-	if len(a.Elements) == 0 {
-		return "[]"
+	return format(a, nil)
+}
+
+// MapValue is a string-keyed collection that remembers the order its keys
+// were first inserted in, so printing and iteration are deterministic.
+type MapValue struct {
+	Keys    []string
+	Entries map[string]RuntimeValue
+}
+
+func (m *MapValue) Type() ValueTypes {
+	return MAP_VALUE
+}
+
+func (m *MapValue) String() string {
+	return format(m, nil)
+}
+
+// format returns how value is printed. active holds the arrays, maps and
+// instances value is printed inside of; one that contains itself is printed
+// as [...] or {...} where it comes back around, instead of forever.
+func format(value RuntimeValue, active []RuntimeValue) string {
+	switch v := value.(type) {
+	case *ArrayValue:
+		if slices.Contains(active, value) {
+			return "[...]"
+		}
+		if len(v.Elements) == 0 {
+			return "[]"
+		}
+
+		active = append(active, v)
+		var elements []string
+		for _, elem := range v.Elements {
+			elements = append(elements, format(elem, active))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *MapValue:
+		if slices.Contains(active, value) {
+			return "{...}"
+		}
+		if len(v.Keys) == 0 {
+			return "{}"
+		}
+
+		active = append(active, v)
+		var entries []string
+		for _, key := range v.Keys {
+			entries = append(entries, fmt.Sprintf("\"%s\": %s", key, format(v.Entries[key], active)))
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case *InstanceValue:
+		if slices.Contains(active, value) {
+			return v.Class.Name + " {...}"
+		}
+		return v.Class.Name + " " + format(v.Fields, append(active, v))
+	default:
+		return value.String()
+	}
+}
+
+func (m *MapValue) Get(key string) (RuntimeValue, bool) {
+	value, exists := m.Entries[key]
+	return value, exists
+}
+
+func (m *MapValue) Set(key string, value RuntimeValue) {
+	if _, exists := m.Entries[key]; !exists {
+		m.Keys = append(m.Keys, key)
 	}
+	m.Entries[key] = value
+}
 
-	var elements []string
-	for _, elem := range a.Elements {
-		elements = append(elements, fmt.Sprintf("%v", elem))
+func (m *MapValue) Delete(key string) bool {
+	if _, exists := m.Entries[key]; !exists {
+		return false
 	}
-	return "[" + strings.Join(elements, ", ") + "]"
+
+	delete(m.Entries, key)
+	m.Keys = slices.DeleteFunc(m.Keys, func(k string) bool { return k == key })
+	return true
 }
 
 func NIL() *NilValue {
@@ -140,10 +349,18 @@ func BOOLEAN(value bool) *BooleanValue {
 	return &BooleanValue{ Value: value }
 }
 
+func NUMBER(value float64) *NumberValue {
+	return &NumberValue{Value: value}
+}
+
 func ARRAY(elements []RuntimeValue) *ArrayValue {
 	return &ArrayValue{Elements: elements}
 }
 
+func MAP() *MapValue {
+	return &MapValue{Entries: map[string]RuntimeValue{}}
+}
+
 func NATIVE_FUNCTION(name string, call func([]RuntimeValue, Environment) RuntimeValue) *NativeFunctionValue {
 	return &NativeFunctionValue{
 		Name: name,
@@ -159,3 +376,27 @@ func FUNCTION(name string, params []string, body ast.Statement, closure Environm
 		Closure:    closure,
 	}
 }
+
+func CLASS(name string, superclass *ClassValue, methods map[string]Method) *ClassValue {
+	return &ClassValue{
+		Name:       name,
+		Superclass: superclass,
+		Methods:    methods,
+	}
+}
+
+func MODULE(name string, path string, exports []string, env Environment) *ModuleValue {
+	return &ModuleValue{
+		Name:    name,
+		Path:    path,
+		Exports: exports,
+		env:     env,
+	}
+}
+
+func INSTANCE(class *ClassValue) *InstanceValue {
+	return &InstanceValue{
+		Class:  class,
+		Fields: MAP(),
+	}
+}
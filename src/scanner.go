@@ -1,9 +1,11 @@
-package src
+package mutex
 
 import (
 	"fmt"
 	"strconv"
+	"unicode/utf8"
 
+	"github.com/caelondev/mutex/src/errors"
 	"github.com/caelondev/mutex/src/frontend/lexer"
 	"github.com/caelondev/mutex/src/helpers"
 )
@@ -11,10 +13,15 @@ import (
 type Scanner struct {
 	SourceCode []rune
 	Tokens []*lexer.Token
+	Errors errors.ErrorList
 
 	Start int
 	Current int
 	Line int
+	LineStart int // Offset of the first rune on the current line
+	Offset int // Byte offset of the current rune
+
+	StartPosition lexer.Position
 }
 
 func NewScanner(sourceCode string) *Scanner {
@@ -24,20 +31,26 @@ func NewScanner(sourceCode string) *Scanner {
 		Start: 0,
 		Current: 0,
 		Line: 1,
+		LineStart: 0,
 	}
 }
 
 func (s *Scanner) ScanTokens() []*lexer.Token {
 	for !s.isEOF() {
 		s.Start = s.Current
+		s.StartPosition = s.position()
 		s.ScanToken()
 	}
 
+	s.StartPosition = s.position()
 	s.Tokens = append(s.Tokens, &lexer.Token{
 		TokenType: lexer.EOF,
 		Lexeme: "",
 		Literal: nil,
 		Line: s.Line,
+		Column: s.StartPosition.Column,
+		Offset: s.Offset,
+		End: s.StartPosition,
 	})
 
 	return s.Tokens
@@ -84,7 +97,11 @@ func (s *Scanner) ScanToken() {
 	case '>':
 		s.addToken(helpers.Ternary(s.match('='), lexer.GREATER_EQUAL, lexer.GREATER).(lexer.TokenType))
 	case '=':
-		s.addToken(helpers.Ternary(s.match('='), lexer.EQUAL_TO, lexer.ASSIGNMENT).(lexer.TokenType))
+		if s.match('>') {
+			s.addToken(lexer.ARROW)
+		} else {
+			s.addToken(helpers.Ternary(s.match('='), lexer.EQUAL_TO, lexer.ASSIGNMENT).(lexer.TokenType))
+		}
 	case '!':
 		if s.peek() == '=' {
 			s.advance()
@@ -101,7 +118,7 @@ func (s *Scanner) ScanToken() {
 		// Ignore whitespace ---
 		break
 	case '\n':
-		s.Line++
+		s.newLine()
 
 	default:
 		if isNumber(c) {
@@ -109,7 +126,7 @@ func (s *Scanner) ScanToken() {
 		} else if isAlphabet(c) {	
 			s.handleIdentifier()
 		} else {
-			mutex.ReportError(s.Line, fmt.Sprintf("Unexpected token found: %c", c))
+			s.reportError(fmt.Sprintf("Unexpected token found: %c", c))
 		}
 	}
 }
@@ -166,8 +183,7 @@ func (s *Scanner) handleNumber() {
 	// Handle decimal part
 	if s.peek() == '.' {
 		if !isNumber(s.peekNext()) { // no number after dot
-			mutex.ReportError(s.Line,
-				fmt.Sprintf("Expected number after '.' but got '%c'", s.peekNext()))
+			s.reportError(fmt.Sprintf("Expected number after '.' but got '%c'", s.peekNext()))
 			return
 		}
 
@@ -182,8 +198,7 @@ func (s *Scanner) handleNumber() {
 	value := string(s.SourceCode[s.Start:s.Current])
 	parsedNumber, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		mutex.ReportError(s.Line,
-			fmt.Sprintf("Failed to parse number '%s': %s", value, err))
+		s.reportError(fmt.Sprintf("Failed to parse number '%s': %s", value, err))
 		return
 	}
 
@@ -193,7 +208,9 @@ func (s *Scanner) handleNumber() {
 func (s *Scanner) handleMultilineString() {
 	for s.peek() != '`' && !s.isEOF() {
 		if (s.peek() == '\n') {
-			s.Line++
+			s.advance();
+			s.newLine()
+			continue
 		}
 		s.advance();
 	}
@@ -202,7 +219,7 @@ func (s *Scanner) handleMultilineString() {
 		currentValue := string(
 			s.SourceCode[s.Start + 1 : s.Current],
 			)
-		mutex.ReportError(s.Line, fmt.Sprintf("Missing closing string ('`') after string value \"%s\"", currentValue))
+		s.reportError(fmt.Sprintf("Missing closing string ('`') after string value \"%s\"", currentValue))
 		return;
 	}
 
@@ -223,7 +240,7 @@ func (s *Scanner) handleString(c rune) {
 				s.SourceCode[s.Start + 1 : s.Current],
 			)
 
-			mutex.ReportError(s.Line, fmt.Sprintf("Missing closing string ('%c') after string value \"%s\"", c, currentValue))
+			s.reportError(fmt.Sprintf("Missing closing string ('%c') after string value \"%s\"", c, currentValue))
 			return
 		}
 		s.advance();
@@ -233,7 +250,7 @@ func (s *Scanner) handleString(c rune) {
 		currentValue := string(
 			s.SourceCode[s.Start + 1 : s.Current],
 		)
-		mutex.ReportError(s.Line, fmt.Sprintf("Missing closing string ('\"') after string value \"%s\"", currentValue))
+		s.reportError(fmt.Sprintf("Missing closing string ('\"') after string value \"%s\"", currentValue))
 		return;
 	}
 
@@ -258,7 +275,9 @@ func (s *Scanner) handleSlash() {
 
 		for !(s.peek() == '*' && s.peekNext() == '/') && !s.isEOF() {
 			if s.peek() == '\n' {
-				s.Line++
+				s.advance()
+				s.newLine()
+				continue
 			}
 			s.advance() // Eat tokens until */ ---
 		}
@@ -274,6 +293,7 @@ func (s *Scanner) handleSlash() {
 func (s *Scanner) advance() rune {
 	result := s.SourceCode[s.Current]
 	s.Current++
+	s.Offset += utf8.RuneLen(result)
 	return result
 }
 
@@ -294,10 +314,37 @@ func (s *Scanner) addTokenWithLiteral(tokenType lexer.TokenType, literal any) {
 		TokenType: tokenType,
 		Lexeme:    text,
 		Literal:   literal,
-		Line:      s.Line,
+		Line:      s.StartPosition.Line,
+		Column:    s.StartPosition.Column,
+		Offset:    s.StartPosition.Offset,
+		End:       s.position(),
 	})
 }
 
+func (s *Scanner) position() lexer.Position {
+	return lexer.Position{
+		Line:   s.Line,
+		Column: s.Current - s.LineStart + 1,
+		Offset: s.Offset,
+	}
+}
+
+func (s *Scanner) reportError(message string) {
+	span := lexer.Span{Start: s.StartPosition, End: s.position()}
+
+	s.Errors = append(s.Errors, &errors.ParseError{
+		Message: message,
+		Span:    span,
+		Line:    span.Start.Line,
+		Column:  span.Start.Column,
+	})
+}
+
+func (s *Scanner) newLine() {
+	s.Line++
+	s.LineStart = s.Current
+}
+
 func (s *Scanner) match(expected rune) bool {
 	if s.isEOF() {
 		return false
@@ -306,7 +353,7 @@ func (s *Scanner) match(expected rune) bool {
 		return false
 	}
 
-	s.Current++
+	s.advance()
 	return true
 }
 
@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"github.com/caelondev/mutex/src/frontend/lexer"
+	"github.com/caelondev/mutex/src/runtime"
+)
+
+type Opcode byte
+
+// Operands follow their opcode in the code stream. Unless noted otherwise an
+// operand is a 32-bit big-endian index or count, so no program the tree
+// walker can run is too large to compile.
+const (
+	OP_CONSTANT      Opcode = iota // constant: push Constants[constant]
+	OP_NIL                         // push nil
+	OP_TRUE                        // push true
+	OP_FALSE                       // push false
+	OP_POP                         // discard the top of the stack
+	OP_RESULT                      // pop into the result register of the program
+	OP_CLEAR_RESULT                // set the result register to nil
+	OP_RESERVE                     // count: push count slots for hoisted variables
+	OP_END_SCOPE                   // slot: close upvalues and drop every slot from slot up
+	OP_DEFINE_GLOBAL               // name, constant (1 byte): declare a global from the popped value
+	OP_GET_GLOBAL                  // name: push the global called Constants[name]
+	OP_SET_GLOBAL                  // name: assign the popped value to a global
+	OP_GET_LOCAL                   // slot: push a local of the current frame
+	OP_SET_LOCAL                   // slot: assign the popped value to a local
+	OP_GET_UPVALUE                 // index: push a variable captured by the closure
+	OP_SET_UPVALUE                 // index: assign the popped value to a captured variable
+	OP_BINARY                      // token: pop two operands and push Tokens[token] applied to them
+	OP_UNARY                       // token: apply a prefix operator to the top of the stack
+	OP_POSTFIX                     // token: push the value ++ or -- updates the top of the stack to
+	OP_TRUTHY                      // replace the top of the stack with its truthiness
+	OP_JUMP                        // offset: jump forward
+	OP_JUMP_IF_FALSE               // offset: pop a condition and jump forward when it is falsy
+	OP_LOOP                        // offset: jump backward
+	OP_ARRAY                       // count: pop count elements into an array
+	OP_MAP                         // count: pop count key/value pairs into a map
+	OP_GET_INDEX                   // pop an index and an object, push object[index]
+	OP_SET_INDEX                   // pop a value, an index and an object, assign object[index]
+	OP_GET_MEMBER                  // name: replace an object with object.name
+	OP_SET_MEMBER                  // name: pop a value and an object, assign object.name
+	OP_CALL                        // argc: call the value below argc arguments
+	OP_CLOSURE                     // function, then per upvalue: local (1 byte), index
+	OP_RETURN                      // return the popped value from the current frame
+	OP_END                         // return the result register from the program
+	OP_CLASS                       // name: push a new class without methods
+	OP_SUPERCLASS                  // class name, superclass name: check the top of the stack can be inherited from
+	OP_INHERIT                     // pop a superclass and set it on the class below it
+	OP_METHOD                      // name: pop a closure and add it to the class below it
+	OP_GET_SUPER                   // name: pop a superclass and an instance, push the bound superclass method
+	OP_ITERATE                     // replace an iterable with an iterator over its items
+	OP_NEXT                        // slot, offset: push the next item of the iterator in slot, or jump forward when done
+	OP_RAISE                       // message: raise a runtime error
+	OP_IMPORT                      // path: push the namespace of the module at path
+	OP_TRY                         // slot, offset: on an error, drop every slot from slot up, push the caught value and jump forward
+	OP_END_TRY                     // remove the innermost error handler
+	OP_THROW                       // raise the popped value as an error
+)
+
+// Chunk is the compiled code of one function.
+type Chunk struct {
+	Code      []byte
+	Spans     []lexer.Span // Source span of the node that emitted each byte of Code
+	Constants []runtime.RuntimeValue
+	Tokens    []lexer.Token // Operator tokens, kept so errors can point at them
+
+	names map[string]int // Constant index of every name already in Constants
+}
+
+func newChunk() *Chunk {
+	return &Chunk{names: map[string]int{}}
+}
+
+func (c *Chunk) write(b byte, span lexer.Span) {
+	c.Code = append(c.Code, b)
+	c.Spans = append(c.Spans, span)
+}
+
+func (c *Chunk) addConstant(value runtime.RuntimeValue) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+// addName returns the constant index of the string name, adding it once.
+func (c *Chunk) addName(name string) int {
+	if index, ok := c.names[name]; ok {
+		return index
+	}
+
+	index := c.addConstant(&runtime.StringValue{Value: name})
+	c.names[name] = index
+	return index
+}
+
+func (c *Chunk) addToken(token lexer.Token) int {
+	c.Tokens = append(c.Tokens, token)
+	return len(c.Tokens) - 1
+}
@@ -0,0 +1,869 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/frontend/ast"
+	"github.com/caelondev/mutex/src/frontend/lexer"
+	"github.com/caelondev/mutex/src/runtime"
+)
+
+// local is a variable that lives in a stack slot. Its index in
+// compiler.locals is its slot in the frame.
+type local struct {
+	name     string
+	depth    int
+	constant bool
+	declared bool // False while the slot is only reserved ahead of the declaration
+}
+
+type upvalue struct {
+	name     string
+	index    int  // Slot in the enclosing frame, or upvalue of the enclosing closure
+	local    bool // Whether index is a slot rather than an upvalue
+	constant bool
+}
+
+type loop struct {
+	label     string
+	slots     int // Locals alive outside the loop body
+	tries     int // Try blocks around the loop
+	start     int // Where continue jumps back to, or -1 when it jumps forward
+	breaks    []int
+	continues []int
+}
+
+// tryBlock is a try block, or a catch clause that has a finally block, being
+// compiled. Code jumping out of it must remove its error handler and run its
+// finally block first.
+type tryBlock struct {
+	slots   int                 // Locals alive outside the try statement
+	finally *ast.BlockStatement // nil without a finally block
+}
+
+// compiler compiles one function. Scopes mirror the environments the tree
+// walker creates, so both backends agree on which declaration a name means.
+type compiler struct {
+	enclosing *compiler
+	function  *Function
+	script    bool // Compiling the top level of a program
+	locals    []local
+	upvalues  []upvalue
+	depth     int // 0 is the global scope
+	loops     []*loop
+	tries     []*tryBlock
+	span      lexer.Span
+}
+
+// Compile translates a parsed program into the function the VM runs. Top
+// level declarations become globals of the environment the program runs in;
+// every other variable is resolved to a stack slot ahead of time.
+func Compile(program ast.BlockStatement) (function *Function, err error) {
+	defer errors.Recover(&err)
+
+	c := &compiler{
+		function: &Function{Name: "script", Chunk: newChunk(), Script: true},
+		script:   true,
+		locals:   []local{{declared: true}}, // Slot 0 holds the running closure
+		span:     program.Span(),
+	}
+
+	for _, stmt := range program.Body {
+		c.statement(stmt)
+	}
+	c.emit(OP_END)
+
+	return c.function, nil
+}
+
+func (c *compiler) statement(node ast.Statement) {
+	defer c.at(node.Span())()
+
+	switch n := node.(type) {
+	case *ast.BlockStatement:
+		c.block(n)
+	case *ast.ExpressionStatement:
+		c.expression(n.Expression)
+		if c.script {
+			c.emit(OP_RESULT)
+		} else {
+			c.emit(OP_POP)
+		}
+	case *ast.VariableDeclarationStatement:
+		if n.Value != nil {
+			c.expression(n.Value)
+		} else {
+			c.emit(OP_NIL)
+		}
+		c.defineVariable(n.Identifier, !n.IsMutable)
+		c.clearResult()
+	case *ast.IfStatement:
+		c.ifStatement(n)
+	case *ast.WhileStatement:
+		c.whileStatement(n)
+	case *ast.ForStatement:
+		c.forStatement(n)
+	case *ast.ForInStatement:
+		c.forInStatement(n)
+	case *ast.FunctionDeclaration:
+		c.compileFunction(n.Name, n.Parameters, n.Body, false)
+		c.defineVariable(n.Name, true)
+		c.clearResult()
+	case *ast.ClassDeclaration:
+		c.classDeclaration(n)
+	case *ast.ImportStatement:
+		c.emitOperand(OP_IMPORT, c.chunk().addName(n.Path))
+		c.defineVariable(n.Name, true)
+		c.clearResult()
+	case *ast.ExportStatement:
+		c.statement(n.Declaration)
+	case *ast.ThrowStatement:
+		c.expression(n.Value)
+		c.emit(OP_THROW)
+	case *ast.TryStatement:
+		c.tryStatement(n)
+	case *ast.ReturnStatement:
+		if n.Value != nil {
+			c.expression(n.Value)
+		} else {
+			c.emit(OP_NIL)
+		}
+
+		// The value waits in a hidden slot while finally blocks run ---
+		if len(c.tries) > 0 {
+			slot := c.addLocal("", true)
+			c.exitTries(0)
+			c.emitOperand(OP_GET_LOCAL, slot)
+			c.locals = c.locals[:slot]
+		}
+		c.emit(OP_RETURN)
+	case *ast.BreakStatement:
+		target := c.targetLoop(n.Label)
+		c.exitTries(target.tries)
+		c.dropLocals(target.slots)
+		target.breaks = append(target.breaks, c.emitJump(OP_JUMP))
+	case *ast.ContinueStatement:
+		target := c.targetLoop(n.Label)
+		c.exitTries(target.tries)
+		c.dropLocals(target.slots)
+		if target.start >= 0 {
+			c.emitLoop(target.start)
+		} else {
+			target.continues = append(target.continues, c.emitJump(OP_JUMP))
+		}
+
+	default:
+		c.error(fmt.Sprintf("Unsupported statement node type %T", node))
+	}
+}
+
+func (c *compiler) block(block *ast.BlockStatement) {
+	c.beginScope(block.Body)
+
+	for _, stmt := range block.Body {
+		c.statement(stmt)
+	}
+	if len(block.Body) == 0 {
+		c.clearResult()
+	}
+
+	c.endScope()
+}
+
+func (c *compiler) ifStatement(stmt *ast.IfStatement) {
+	c.expression(stmt.Condition)
+	elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+
+	c.statement(stmt.Consequent)
+
+	if stmt.Alternate == nil && !c.script {
+		c.patchJump(elseJump)
+		return
+	}
+
+	endJump := c.emitJump(OP_JUMP)
+	c.patchJump(elseJump)
+
+	if stmt.Alternate != nil {
+		c.statement(stmt.Alternate)
+	} else {
+		c.clearResult()
+	}
+
+	c.patchJump(endJump)
+}
+
+func (c *compiler) whileStatement(stmt *ast.WhileStatement) {
+	start := len(c.chunk().Code)
+
+	c.expression(stmt.Condition)
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+
+	l := c.beginLoop(stmt.Label, start)
+	c.statement(stmt.Body)
+	c.emitLoop(start)
+
+	c.patchJump(exitJump)
+	c.endLoop(l)
+	c.clearResult()
+}
+
+func (c *compiler) forStatement(stmt *ast.ForStatement) {
+	// The initializer gets a scope of its own, like the tree walker's loop environment ---
+	c.beginScope([]ast.Statement{stmt.Initializer})
+	c.statement(stmt.Initializer)
+
+	start := len(c.chunk().Code)
+	c.expression(stmt.Condition)
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+
+	l := c.beginLoop(stmt.Label, -1)
+	c.statement(stmt.Body)
+
+	for _, jump := range l.continues {
+		c.patchJump(jump)
+	}
+	c.expression(stmt.Increment)
+	c.emit(OP_POP)
+	c.emitLoop(start)
+
+	c.patchJump(exitJump)
+	c.endLoop(l)
+	c.endScope()
+	c.clearResult()
+}
+
+func (c *compiler) forInStatement(stmt *ast.ForInStatement) {
+	c.expression(stmt.Iterable)
+	c.emit(OP_ITERATE)
+
+	c.beginScope(nil)
+	iteratorSlot := c.addLocal("", true)
+
+	start := len(c.chunk().Code)
+	c.emitOperand(OP_NEXT, iteratorSlot)
+	exitJump := len(c.chunk().Code)
+	c.emitWord(0)
+
+	l := c.beginLoop(stmt.Label, start)
+
+	// Each iteration gets a fresh binding, so closures capture their own item ---
+	c.beginScope(nil)
+	c.addLocal(stmt.Variable, !stmt.IsMutable)
+	c.statement(stmt.Body)
+	c.endScope()
+	c.emitLoop(start)
+
+	c.patchJump(exitJump)
+	c.endLoop(l)
+	c.endScope()
+	c.clearResult()
+}
+
+// tryStatement compiles a try statement. The try block and a catch clause
+// followed by a finally block run under error handlers; the code for the
+// finally block is repeated on every way out of the statement.
+func (c *compiler) tryStatement(stmt *ast.TryStatement) {
+	slots := len(c.locals)
+	try := &tryBlock{slots: slots, finally: stmt.Finally}
+
+	handler := c.emitHandler(slots)
+	c.tries = append(c.tries, try)
+	c.statement(stmt.Body)
+	c.tries = c.tries[:len(c.tries)-1]
+	c.emit(OP_END_TRY)
+	exits := []int{c.emitJump(OP_JUMP)}
+	c.patchJump(handler)
+
+	// Handlers leave the caught value in slot slots ---
+	if stmt.Catch != nil {
+		c.beginScope(nil)
+		c.addLocal(stmt.CatchName, false)
+
+		if stmt.Finally != nil {
+			handler = c.emitHandler(slots)
+			c.tries = append(c.tries, try)
+		}
+		c.statement(stmt.Catch)
+		if stmt.Finally != nil {
+			c.tries = c.tries[:len(c.tries)-1]
+			c.emit(OP_END_TRY)
+		}
+
+		c.endScope()
+		exits = append(exits, c.emitJump(OP_JUMP))
+		if stmt.Finally != nil {
+			c.patchJump(handler)
+		}
+	}
+
+	// An error nothing caught is raised again once the finally block ran ---
+	if stmt.Finally != nil {
+		c.beginScope(nil)
+		slot := c.addLocal("", true)
+		c.finallyBlock(stmt.Finally)
+		c.emitOperand(OP_GET_LOCAL, slot)
+		c.emit(OP_THROW)
+		c.depth--
+		c.locals = c.locals[:slot]
+	}
+
+	for _, exit := range exits {
+		c.patchJump(exit)
+	}
+	if stmt.Finally != nil {
+		c.finallyBlock(stmt.Finally)
+	}
+}
+
+// emitHandler emits an OP_TRY keeping the locals below slot and returns the
+// operand to patch with the handler's address.
+func (c *compiler) emitHandler(slot int) int {
+	c.emitOperand(OP_TRY, slot)
+	offset := len(c.chunk().Code)
+	c.emitWord(0)
+	return offset
+}
+
+// exitTries emits the code leaving every try block from c.tries[from] up,
+// innermost first, for a return, break or continue jumping out of them.
+func (c *compiler) exitTries(from int) {
+	for i := len(c.tries) - 1; i >= from; i-- {
+		c.emit(OP_END_TRY)
+		if c.tries[i].finally == nil {
+			continue
+		}
+
+		// The finally block sees the variables around the try statement
+		// only, though the slots of the inner ones are still in use ---
+		locals, tries, loops := c.locals, c.tries, c.loops
+		c.locals = slices.Clone(c.locals)
+		for slot := c.tries[i].slots; slot < len(c.locals); slot++ {
+			c.locals[slot].name = ""
+		}
+		c.tries = c.tries[:i]
+		c.loops = slices.DeleteFunc(slices.Clone(c.loops), func(l *loop) bool { return l.tries > i })
+
+		c.finallyBlock(tries[i].finally)
+		c.locals, c.tries, c.loops = locals, tries, loops
+	}
+}
+
+// finallyBlock compiles a finally block, which leaves the result of the
+// program alone like the tree walker does.
+func (c *compiler) finallyBlock(block *ast.BlockStatement) {
+	script := c.script
+	c.script = false
+	c.statement(block)
+	c.script = script
+}
+
+func (c *compiler) classDeclaration(stmt *ast.ClassDeclaration) {
+	name := c.chunk().addName(stmt.Name)
+	hasSuperclass := stmt.Superclass != ""
+
+	if hasSuperclass {
+		c.getVariable(stmt.Superclass)
+		c.emitOperand(OP_SUPERCLASS, name)
+		c.emitWord(c.chunk().addName(stmt.Superclass))
+		c.emit(OP_POP)
+	}
+
+	c.emitOperand(OP_CLASS, name)
+	c.defineVariable(stmt.Name, true)
+
+	// Methods reach the superclass through 'super' ---
+	if hasSuperclass {
+		c.beginScope(nil)
+		c.getVariable(stmt.Superclass)
+		c.addLocal("super", true)
+	}
+
+	c.getVariable(stmt.Name)
+	if hasSuperclass {
+		c.getVariable("super")
+		c.emit(OP_INHERIT)
+	}
+
+	for _, method := range stmt.Methods {
+		c.compileFunction(method.Name, method.Parameters, method.Body, true)
+		c.emitOperand(OP_METHOD, c.chunk().addName(method.Name))
+	}
+	c.emit(OP_POP)
+
+	if hasSuperclass {
+		c.endScope()
+	}
+	c.clearResult()
+}
+
+// compileFunction compiles a function body with a compiler of its own and
+// emits the instruction creating its closure. Methods keep 'this' in slot 0.
+func (c *compiler) compileFunction(name string, parameters []string, body ast.Statement, method bool) {
+	fc := &compiler{
+		enclosing: c,
+		function:  &Function{Name: name, Arity: len(parameters), Chunk: newChunk()},
+		depth:     1,
+		span:      c.span,
+	}
+
+	receiver := ""
+	if method {
+		receiver = "this"
+	}
+	fc.locals = append(fc.locals, local{name: receiver, depth: 1, constant: true, declared: true})
+
+	for _, parameter := range parameters {
+		if fc.resolveLocal(parameter, false) >= 0 {
+			fc.raise(fmt.Sprintf("Cannot declare variable \"%s\" as it is already defined", parameter))
+		}
+		fc.locals = append(fc.locals, local{name: parameter, depth: 1, declared: true})
+	}
+
+	fc.statement(body)
+	fc.emit(OP_NIL)
+	fc.emit(OP_RETURN)
+
+	for _, upvalue := range fc.upvalues {
+		fc.function.Upvalues = append(fc.function.Upvalues, upvalue.name)
+	}
+
+	c.emitOperand(OP_CLOSURE, c.chunk().addConstant(fc.function))
+	for _, upvalue := range fc.upvalues {
+		if upvalue.local {
+			c.emitByte(1)
+		} else {
+			c.emitByte(0)
+		}
+		c.emitWord(upvalue.index)
+	}
+}
+
+func (c *compiler) expression(node ast.Expression) {
+	defer c.at(node.Span())()
+
+	switch n := node.(type) {
+	case *ast.NumberExpression:
+		c.emitOperand(OP_CONSTANT, c.chunk().addConstant(&runtime.NumberValue{Value: n.Value}))
+	case *ast.StringExpression:
+		c.emitOperand(OP_CONSTANT, c.chunk().addConstant(&runtime.StringValue{Value: n.Value}))
+	case *ast.SymbolExpression:
+		c.getVariable(n.Value)
+	case *ast.BinaryExpression:
+		c.binaryExpression(n)
+	case *ast.AssignmentExpression:
+		symbol, ok := n.Assignee.(*ast.SymbolExpression)
+		if !ok {
+			c.raise("Invalid assignment target, expected a variable")
+			c.emit(OP_NIL)
+			return
+		}
+
+		c.expression(n.NewValue)
+		c.setVariable(symbol.Value)
+		c.emit(OP_NIL)
+	case *ast.UnaryExpression:
+		c.expression(n.Operand)
+		c.emitOperand(OP_UNARY, c.chunk().addToken(n.Operator))
+	case *ast.PostfixExpression:
+		symbol, ok := n.Operand.(*ast.SymbolExpression)
+		if !ok {
+			restore := c.at(n.Operator.Span())
+			c.raise("Postfix operators can only be applied to variables")
+			restore()
+			c.emit(OP_NIL)
+			return
+		}
+
+		// Leaves the old value behind as the result ---
+		c.getVariable(symbol.Value)
+		c.emitOperand(OP_POSTFIX, c.chunk().addToken(n.Operator))
+		c.setVariable(symbol.Value)
+	case *ast.ArrayExpression:
+		for _, element := range n.Elements {
+			c.expression(element)
+		}
+		c.emitOperand(OP_ARRAY, len(n.Elements))
+	case *ast.ArrayIndexExpression:
+		c.expression(n.Object)
+		c.expression(n.Index)
+		c.emit(OP_GET_INDEX)
+	case *ast.ArrayIndexAssignmentExpression:
+		c.expression(n.Object)
+		c.expression(n.Index)
+		c.expression(n.NewValue)
+		c.emit(OP_SET_INDEX)
+		c.emit(OP_NIL)
+	case *ast.CallExpression:
+		c.expression(n.Callee)
+		for _, argument := range n.Arguments {
+			c.expression(argument)
+		}
+		c.emitOperand(OP_CALL, len(n.Arguments))
+	case *ast.MapExpression:
+		for _, entry := range n.Entries {
+			c.emitOperand(OP_CONSTANT, c.chunk().addName(entry.Key))
+			c.expression(entry.Value)
+		}
+		c.emitOperand(OP_MAP, len(n.Entries))
+	case *ast.MemberExpression:
+		c.expression(n.Object)
+		c.emitOperand(OP_GET_MEMBER, c.chunk().addName(n.Property))
+	case *ast.MemberAssignmentExpression:
+		c.expression(n.Object)
+		c.expression(n.NewValue)
+		c.emitOperand(OP_SET_MEMBER, c.chunk().addName(n.Property))
+		c.emit(OP_NIL)
+	case *ast.FunctionExpression:
+		c.compileFunction("anonymous", n.Parameters, n.Body, false)
+	case *ast.ThisExpression:
+		c.getVariable("this")
+	case *ast.SuperExpression:
+		c.getVariable("this")
+		c.getVariable("super")
+		c.emitOperand(OP_GET_SUPER, c.chunk().addName(n.Method))
+
+	default:
+		c.error(fmt.Sprintf("Unsupported expression node type %T", node))
+	}
+}
+
+func (c *compiler) binaryExpression(expr *ast.BinaryExpression) {
+	// Logical operators short-circuit and always produce a boolean ---
+	switch expr.Operator.TokenType {
+	case lexer.AND:
+		c.expression(expr.Left)
+		falseJump := c.emitJump(OP_JUMP_IF_FALSE)
+		c.expression(expr.Right)
+		c.emit(OP_TRUTHY)
+		endJump := c.emitJump(OP_JUMP)
+		c.patchJump(falseJump)
+		c.emit(OP_FALSE)
+		c.patchJump(endJump)
+		return
+
+	case lexer.OR:
+		c.expression(expr.Left)
+		rightJump := c.emitJump(OP_JUMP_IF_FALSE)
+		c.emit(OP_TRUE)
+		endJump := c.emitJump(OP_JUMP)
+		c.patchJump(rightJump)
+		c.expression(expr.Right)
+		c.emit(OP_TRUTHY)
+		c.patchJump(endJump)
+		return
+	}
+
+	c.expression(expr.Left)
+	c.expression(expr.Right)
+	c.emitOperand(OP_BINARY, c.chunk().addToken(expr.Operator))
+}
+
+// Variables ---
+
+// beginScope opens a scope and reserves a slot for every variable, function
+// and class declared directly in body. Closures created before a declaration
+// runs can then already capture the slot, which is what lets local functions
+// call each other regardless of their order.
+func (c *compiler) beginScope(body []ast.Statement) {
+	c.depth++
+
+	reserved := 0
+	for _, stmt := range body {
+		name, constant := declaredName(stmt)
+		if name == "" || c.inScope(name) {
+			continue
+		}
+
+		c.locals = append(c.locals, local{name: name, depth: c.depth, constant: constant})
+		reserved++
+	}
+
+	if reserved > 0 {
+		c.emitOperand(OP_RESERVE, reserved)
+	}
+}
+
+func (c *compiler) endScope() {
+	c.depth--
+
+	slots := len(c.locals)
+	for slots > 0 && c.locals[slots-1].depth > c.depth {
+		slots--
+	}
+
+	c.dropLocals(slots)
+	c.locals = c.locals[:slots]
+}
+
+// dropLocals emits the code discarding every local from slot slots up,
+// without forgetting them at compile time.
+func (c *compiler) dropLocals(slots int) {
+	if len(c.locals) > slots {
+		c.emitOperand(OP_END_SCOPE, slots)
+	}
+}
+
+func declaredName(stmt ast.Statement) (name string, constant bool) {
+	switch n := stmt.(type) {
+	case *ast.VariableDeclarationStatement:
+		return n.Identifier, !n.IsMutable
+	case *ast.FunctionDeclaration:
+		return n.Name, true
+	case *ast.ClassDeclaration:
+		return n.Name, true
+	}
+	return "", false
+}
+
+func (c *compiler) inScope(name string) bool {
+	for i := len(c.locals) - 1; i >= 0 && c.locals[i].depth == c.depth; i-- {
+		if c.locals[i].name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addLocal turns the value on top of the stack into a new local.
+func (c *compiler) addLocal(name string, constant bool) int {
+	c.locals = append(c.locals, local{name: name, depth: c.depth, constant: constant, declared: true})
+	return len(c.locals) - 1
+}
+
+// defineVariable declares name with the value on top of the stack.
+func (c *compiler) defineVariable(name string, constant bool) {
+	if c.depth == 0 {
+		c.emitOperand(OP_DEFINE_GLOBAL, c.chunk().addName(name))
+		if constant {
+			c.emitByte(1)
+		} else {
+			c.emitByte(0)
+		}
+		return
+	}
+
+	for i := len(c.locals) - 1; i >= 0 && c.locals[i].depth == c.depth; i-- {
+		if c.locals[i].name != name {
+			continue
+		}
+
+		if c.locals[i].declared {
+			c.emit(OP_POP)
+			c.raise(fmt.Sprintf("Cannot declare variable \"%s\" as it is already defined", name))
+			return
+		}
+
+		c.locals[i].declared = true
+		c.locals[i].constant = constant
+		c.emitOperand(OP_SET_LOCAL, i)
+		return
+	}
+
+	c.addLocal(name, constant)
+}
+
+// resolveLocal returns the slot of the innermost local called name, or -1.
+// Reserved slots only count when hoisted is set: code in the same function
+// runs in source order and cannot see a declaration that has not run yet.
+func (c *compiler) resolveLocal(name string, hoisted bool) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i].name == name && (c.locals[i].declared || hoisted) {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveUpvalue returns the index of the upvalue capturing name from an
+// enclosing function, or -1 when name is global.
+func (c *compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+
+	if slot := c.enclosing.resolveLocal(name, true); slot >= 0 {
+		return c.addUpvalue(upvalue{name: name, index: slot, local: true, constant: c.enclosing.locals[slot].constant})
+	}
+
+	if index := c.enclosing.resolveUpvalue(name); index >= 0 {
+		return c.addUpvalue(upvalue{name: name, index: index, constant: c.enclosing.upvalues[index].constant})
+	}
+
+	return -1
+}
+
+func (c *compiler) addUpvalue(captured upvalue) int {
+	for i, existing := range c.upvalues {
+		if existing.index == captured.index && existing.local == captured.local {
+			return i
+		}
+	}
+
+	c.upvalues = append(c.upvalues, captured)
+	return len(c.upvalues) - 1
+}
+
+func (c *compiler) getVariable(name string) {
+	if slot := c.resolveLocal(name, false); slot >= 0 {
+		c.emitOperand(OP_GET_LOCAL, slot)
+		return
+	}
+
+	if index := c.resolveUpvalue(name); index >= 0 {
+		c.emitOperand(OP_GET_UPVALUE, index)
+		return
+	}
+
+	// The global constants cannot be redeclared, so unless a local shadows
+	// them their value is known ---
+	switch name {
+	case "nil":
+		c.emit(OP_NIL)
+	case "true":
+		c.emit(OP_TRUE)
+	case "false":
+		c.emit(OP_FALSE)
+	default:
+		c.emitOperand(OP_GET_GLOBAL, c.chunk().addName(name))
+	}
+}
+
+// setVariable assigns the value on top of the stack to name.
+func (c *compiler) setVariable(name string) {
+	if slot := c.resolveLocal(name, false); slot >= 0 {
+		if c.locals[slot].constant {
+			c.reassignConstant(name)
+			return
+		}
+		c.emitOperand(OP_SET_LOCAL, slot)
+		return
+	}
+
+	if index := c.resolveUpvalue(name); index >= 0 {
+		if c.upvalues[index].constant {
+			c.reassignConstant(name)
+			return
+		}
+		c.emitOperand(OP_SET_UPVALUE, index)
+		return
+	}
+
+	c.emitOperand(OP_SET_GLOBAL, c.chunk().addName(name))
+}
+
+func (c *compiler) reassignConstant(name string) {
+	c.emit(OP_POP)
+	c.raise(fmt.Sprintf("Cannot re-assign constant variable \"%s\"", name))
+}
+
+// Loops ---
+
+func (c *compiler) beginLoop(label string, start int) *loop {
+	l := &loop{label: label, slots: len(c.locals), tries: len(c.tries), start: start}
+	c.loops = append(c.loops, l)
+	return l
+}
+
+func (c *compiler) endLoop(l *loop) {
+	for _, jump := range l.breaks {
+		c.patchJump(jump)
+	}
+	c.loops = c.loops[:len(c.loops)-1]
+}
+
+// targetLoop finds the loop a break or continue with label leaves. The
+// parser has already checked that it exists.
+func (c *compiler) targetLoop(label string) *loop {
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		if label == "" || c.loops[i].label == label {
+			return c.loops[i]
+		}
+	}
+
+	c.error(fmt.Sprintf("No enclosing loop labeled '%s'", label))
+	return nil
+}
+
+// Emitting ---
+
+func (c *compiler) chunk() *Chunk {
+	return c.function.Chunk
+}
+
+// at makes span the location of the code emitted until the returned
+// function restores the previous one.
+func (c *compiler) at(span lexer.Span) func() {
+	previous := c.span
+	c.span = span
+	return func() { c.span = previous }
+}
+
+func (c *compiler) emit(op Opcode) {
+	c.chunk().write(byte(op), c.span)
+}
+
+func (c *compiler) emitByte(b byte) {
+	c.chunk().write(b, c.span)
+}
+
+func (c *compiler) emitWord(value int) {
+	if uint64(value) > math.MaxUint32 {
+		c.error("Program too large to compile: more than 4294967295 constants, slots or bytes in a jump")
+	}
+	c.emitByte(byte(value >> 24))
+	c.emitByte(byte(value >> 16))
+	c.emitByte(byte(value >> 8))
+	c.emitByte(byte(value))
+}
+
+func (c *compiler) emitOperand(op Opcode, operand int) {
+	c.emit(op)
+	c.emitWord(operand)
+}
+
+func (c *compiler) emitJump(op Opcode) int {
+	c.emitOperand(op, 0)
+	return len(c.chunk().Code) - 4
+}
+
+// patchJump points the jump whose operand is at offset to the next instruction.
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 4
+	if uint64(jump) > math.MaxUint32 {
+		c.error("Program too large to compile: more than 4294967295 bytes in a jump")
+	}
+
+	c.chunk().Code[offset] = byte(jump >> 24)
+	c.chunk().Code[offset+1] = byte(jump >> 16)
+	c.chunk().Code[offset+2] = byte(jump >> 8)
+	c.chunk().Code[offset+3] = byte(jump)
+}
+
+func (c *compiler) emitLoop(start int) {
+	c.emitOperand(OP_LOOP, len(c.chunk().Code)-start+5)
+}
+
+// clearResult resets the value a program reports after a statement that
+// produces none, matching what the tree walker returns for it.
+func (c *compiler) clearResult() {
+	if c.script {
+		c.emit(OP_CLEAR_RESULT)
+	}
+}
+
+// raise emits an instruction failing with message once execution reaches it.
+// Mistakes the compiler can see are still reported at run time, exactly when
+// the tree walker would report them.
+func (c *compiler) raise(message string) {
+	c.emitOperand(OP_RAISE, c.chunk().addConstant(&runtime.StringValue{Value: message}))
+}
+
+func (c *compiler) error(message string) {
+	err := errors.NewRuntimeError(nil, message)
+	err.Locate(c.span)
+	panic(err)
+}
@@ -0,0 +1,106 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/caelondev/mutex/src/runtime"
+)
+
+// Function is a compiled function body. It only exists as a constant; the
+// program works with the closures created from it.
+type Function struct {
+	Name     string
+	Arity    int
+	Upvalues []string // Names of the captured variables, for error messages
+	Chunk    *Chunk
+	Script   bool // The top level of a file rather than a function
+}
+
+func (f *Function) Type() runtime.ValueTypes {
+	return runtime.FUNCTION_VALUE
+}
+
+func (f *Function) String() string {
+	return fmt.Sprintf("[ ...function '%s'... ]", f.Name)
+}
+
+// Closure is a function value created by the VM. It prints and reports its
+// type exactly like a function of the tree walker.
+type Closure struct {
+	Function *Function
+	Upvalues []*Upvalue
+	vm       *VM
+}
+
+func (c *Closure) Type() runtime.ValueTypes {
+	return runtime.FUNCTION_VALUE
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+func (c *Closure) Bind(instance *runtime.InstanceValue) runtime.RuntimeValue {
+	return &BoundMethod{Receiver: instance, Method: c}
+}
+
+func (c *Closure) Invoke(args []runtime.RuntimeValue) runtime.RuntimeValue {
+	return c.vm.invoke(c, args)
+}
+
+// BoundMethod is a method taken from an instance, which becomes 'this' when
+// the method is called.
+type BoundMethod struct {
+	Receiver *runtime.InstanceValue
+	Method   *Closure
+}
+
+func (b *BoundMethod) Type() runtime.ValueTypes {
+	return runtime.FUNCTION_VALUE
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+func (b *BoundMethod) Invoke(args []runtime.RuntimeValue) runtime.RuntimeValue {
+	return b.Method.vm.invoke(b, args)
+}
+
+// Upvalue is a variable captured by a closure. While the variable's scope is
+// running it lives in a stack slot; once the scope ends the upvalue keeps the
+// last value itself.
+type Upvalue struct {
+	slot   int
+	open   bool
+	closed runtime.RuntimeValue
+}
+
+// undefinedValue fills the slots reserved for a block's variables until
+// their declarations run, so a closure that reads one too early can tell.
+type undefinedValue struct{}
+
+func (u *undefinedValue) Type() runtime.ValueTypes {
+	return runtime.NIL_VALUE
+}
+
+func (u *undefinedValue) String() string {
+	return "nil"
+}
+
+var undefined = &undefinedValue{}
+
+// iterator walks the items of a for-in loop. It lives in a hidden slot of
+// the loop's scope.
+type iterator struct {
+	items []runtime.RuntimeValue
+	next  int
+}
+
+func (i *iterator) Type() runtime.ValueTypes {
+	return "iterator"
+}
+
+func (i *iterator) String() string {
+	return "iterator"
+}
@@ -0,0 +1,585 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/caelondev/mutex/src/errors"
+	"github.com/caelondev/mutex/src/frontend/lexer"
+	"github.com/caelondev/mutex/src/runtime"
+)
+
+type frame struct {
+	closure   *Closure
+	ip        int
+	base      int  // Stack index of slot 0
+	construct bool // Running a class's init, so the call results in the instance
+}
+
+// handler is where execution resumes when an error is raised inside a try
+// block. It belongs to the frame that ran the OP_TRY installing it.
+type handler struct {
+	frames int // Frame count when it was installed
+	ip     int
+	stack  int // Stack height to go back to
+}
+
+// VM runs compiled programs. Globals and native functions come from the
+// environment it was created with, so a VM and the tree walker can share
+// one global scope.
+type VM struct {
+	env      runtime.Environment
+	stack    []runtime.RuntimeValue
+	frames   []frame
+	upvalues []*Upvalue           // Open upvalues, ordered by slot
+	handlers []handler            // Installed error handlers, innermost last
+	result   runtime.RuntimeValue // Value of the last top-level statement
+}
+
+func New(env runtime.Environment) *VM {
+	return &VM{env: env}
+}
+
+// Run executes a program made by Compile and returns the value of its last
+// statement, or the value it returned. Runtime failures are returned as an
+// *errors.RuntimeError.
+//
+// A native may start a run while another is active, as a host function
+// calling Eval does; the nested run leaves the outer one's state alone.
+func (vm *VM) Run(program *Function) (result runtime.RuntimeValue, err error) {
+	defer errors.Recover(&err)
+	defer vm.reset(len(vm.stack), len(vm.frames), len(vm.handlers), vm.result)
+
+	vm.result = runtime.NIL()
+	return vm.invoke(&Closure{Function: program, vm: vm}, nil), nil
+}
+
+// reset drops whatever a run left above the heights it started at, and
+// brings back the result of the run it was nested in.
+func (vm *VM) reset(height, depth, handlers int, result runtime.RuntimeValue) {
+	vm.closeUpvalues(height)
+	vm.stack = vm.stack[:height]
+	vm.truncate(depth)
+	vm.handlers = vm.handlers[:handlers]
+	vm.result = result
+}
+
+// invoke calls callee with args and runs until the call returns. Natives
+// calling back into compiled functions come through here.
+func (vm *VM) invoke(callee runtime.RuntimeValue, args []runtime.RuntimeValue) runtime.RuntimeValue {
+	depth := len(vm.frames)
+	height := len(vm.stack)
+	handlers := len(vm.handlers)
+
+	// An error leaving the call leaves the VM as the call found it, so
+	// whoever catches the error can keep using it ---
+	defer func() {
+		if r := recover(); r != nil {
+			vm.closeUpvalues(height)
+			vm.stack = vm.stack[:height]
+			vm.truncate(depth)
+			vm.handlers = vm.handlers[:handlers]
+			panic(r)
+		}
+	}()
+
+	vm.push(callee)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+
+	vm.call(len(args))
+	if len(vm.frames) > depth {
+		vm.run(depth)
+	}
+
+	return vm.pop()
+}
+
+// run executes instructions until the frame count drops back to depth.
+func (vm *VM) run(depth int) {
+	for !vm.execute(depth) {
+	}
+}
+
+// execute runs instructions like run. It stops early, returning false, when
+// an error handler of one of the frames it runs caught an error; execution
+// then continues at the handler.
+func (vm *VM) execute(depth int) (done bool) {
+	fr := &vm.frames[len(vm.frames)-1]
+	chunk := fr.closure.Function.Chunk
+	code := chunk.Code
+	start := 0
+
+	// Errors raised without a location point at the current instruction ---
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		if err, ok := r.(*errors.RuntimeError); ok {
+			if err.Span.IsZero() {
+				err.Locate(chunk.Spans[start])
+			}
+			if err.File == "" {
+				err.File = vm.env.Module()
+			}
+			vm.trace(err, depth, start)
+			if vm.catch(err, depth) {
+				return
+			}
+		}
+		panic(r)
+	}()
+
+	readWord := func() int {
+		fr.ip += 4
+		return int(code[fr.ip-4])<<24 | int(code[fr.ip-3])<<16 | int(code[fr.ip-2])<<8 | int(code[fr.ip-1])
+	}
+
+	// Refreshes the cached frame after a call or return ---
+	enter := func() {
+		fr = &vm.frames[len(vm.frames)-1]
+		chunk = fr.closure.Function.Chunk
+		code = chunk.Code
+	}
+
+	for {
+		start = fr.ip
+		op := Opcode(code[fr.ip])
+		fr.ip++
+
+		switch op {
+		case OP_CONSTANT:
+			vm.push(chunk.Constants[readWord()])
+		case OP_NIL:
+			vm.push(runtime.NIL())
+		case OP_TRUE:
+			vm.push(runtime.BOOLEAN(true))
+		case OP_FALSE:
+			vm.push(runtime.BOOLEAN(false))
+		case OP_POP:
+			vm.pop()
+		case OP_RESULT:
+			vm.result = vm.pop()
+		case OP_CLEAR_RESULT:
+			vm.result = runtime.NIL()
+		case OP_RESERVE:
+			for range readWord() {
+				vm.push(undefined)
+			}
+		case OP_END_SCOPE:
+			slot := fr.base + readWord()
+			vm.closeUpvalues(slot)
+			vm.stack = vm.stack[:slot]
+
+		case OP_DEFINE_GLOBAL:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			constant := code[fr.ip] == 1
+			fr.ip++
+			vm.env.DeclareVariable(name, vm.pop(), constant)
+		case OP_GET_GLOBAL:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			vm.push(vm.env.LookupVariable(name))
+		case OP_SET_GLOBAL:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			vm.env.AssignVariable(name, vm.pop())
+		case OP_GET_LOCAL:
+			vm.push(vm.stack[fr.base+readWord()])
+		case OP_SET_LOCAL:
+			vm.stack[fr.base+readWord()] = vm.pop()
+		case OP_GET_UPVALUE:
+			index := readWord()
+			value := vm.upvalue(fr.closure.Upvalues[index])
+			if value == undefined {
+				undeclared(fr.closure.Function.Upvalues[index])
+			}
+			vm.push(value)
+		case OP_SET_UPVALUE:
+			index := readWord()
+			upvalue := fr.closure.Upvalues[index]
+			if vm.upvalue(upvalue) == undefined {
+				undeclared(fr.closure.Function.Upvalues[index])
+			}
+			vm.setUpvalue(upvalue, vm.pop())
+
+		case OP_BINARY:
+			operator := &chunk.Tokens[readWord()]
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(binary(operator, left, right))
+		case OP_UNARY:
+			operator := &chunk.Tokens[readWord()]
+			vm.push(runtime.UnaryOperation(operator, vm.pop()))
+		case OP_POSTFIX:
+			operator := &chunk.Tokens[readWord()]
+			vm.push(runtime.PostfixOperation(operator, vm.peek(0)))
+		case OP_TRUTHY:
+			vm.push(runtime.BOOLEAN(runtime.IsTruthy(vm.pop())))
+
+		case OP_JUMP:
+			offset := readWord()
+			fr.ip += offset
+		case OP_JUMP_IF_FALSE:
+			offset := readWord()
+			if !runtime.IsTruthy(vm.pop()) {
+				fr.ip += offset
+			}
+		case OP_LOOP:
+			offset := readWord()
+			fr.ip -= offset
+			vm.env.Interpreter().Step()
+
+		case OP_ARRAY:
+			count := readWord()
+			elements := slices.Clone(vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			if elements == nil {
+				elements = []runtime.RuntimeValue{}
+			}
+			vm.push(runtime.ARRAY(elements))
+		case OP_MAP:
+			count := readWord()
+			entries := vm.stack[len(vm.stack)-2*count:]
+			mapValue := runtime.MAP()
+			for i := 0; i < len(entries); i += 2 {
+				mapValue.Set(entries[i].(*runtime.StringValue).Value, entries[i+1])
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*count]
+			vm.push(mapValue)
+		case OP_GET_INDEX:
+			index := vm.pop()
+			object := vm.pop()
+			vm.push(runtime.GetIndex(object, index))
+		case OP_SET_INDEX:
+			value := vm.pop()
+			index := vm.pop()
+			object := vm.pop()
+			runtime.SetIndex(object, index, value)
+		case OP_GET_MEMBER:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			vm.push(runtime.GetMember(vm.pop(), name))
+		case OP_SET_MEMBER:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			value := vm.pop()
+			object := vm.pop()
+			runtime.SetMember(object, name, value)
+
+		case OP_CALL:
+			vm.call(readWord())
+			enter()
+		case OP_CLOSURE:
+			function := chunk.Constants[readWord()].(*Function)
+			closure := &Closure{Function: function, Upvalues: make([]*Upvalue, len(function.Upvalues)), vm: vm}
+			for i := range closure.Upvalues {
+				isLocal := code[fr.ip] == 1
+				fr.ip++
+				index := readWord()
+				if isLocal {
+					closure.Upvalues[i] = vm.captureUpvalue(fr.base + index)
+				} else {
+					closure.Upvalues[i] = fr.closure.Upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case OP_RETURN, OP_END:
+			var result runtime.RuntimeValue
+			if op == OP_END {
+				result = vm.result
+			} else {
+				result = vm.pop()
+			}
+			if fr.construct {
+				result = vm.stack[fr.base]
+			}
+
+			vm.closeUpvalues(fr.base)
+			vm.stack = vm.stack[:fr.base]
+			vm.truncate(len(vm.frames) - 1)
+			vm.push(result)
+
+			if len(vm.frames) == depth {
+				return true
+			}
+			enter()
+
+		case OP_CLASS:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			vm.push(runtime.CLASS(name, nil, map[string]runtime.Method{}))
+		case OP_SUPERCLASS:
+			className := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			superclassName := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			runtime.Superclass(className, superclassName, vm.peek(0))
+		case OP_INHERIT:
+			superclass := vm.pop().(*runtime.ClassValue)
+			vm.peek(0).(*runtime.ClassValue).Superclass = superclass
+		case OP_METHOD:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*runtime.ClassValue).Methods[name] = method
+		case OP_GET_SUPER:
+			name := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			superclass := vm.pop().(*runtime.ClassValue)
+			instance := vm.pop().(*runtime.InstanceValue)
+			vm.push(runtime.SuperMethod(superclass, instance, name))
+
+		case OP_ITERATE:
+			vm.push(&iterator{items: runtime.IterationItems(vm.pop())})
+		case OP_NEXT:
+			it := vm.stack[fr.base+readWord()].(*iterator)
+			offset := readWord()
+			if it.next >= len(it.items) {
+				fr.ip += offset
+			} else {
+				vm.push(it.items[it.next])
+				it.next++
+			}
+
+		case OP_RAISE:
+			errors.RaiseRuntime(nil, chunk.Constants[readWord()].(*runtime.StringValue).Value)
+		case OP_IMPORT:
+			path := chunk.Constants[readWord()].(*runtime.StringValue).Value
+			vm.push(runtime.Import(path, vm.env))
+
+		case OP_TRY:
+			slot := fr.base + readWord()
+			offset := readWord()
+			vm.handlers = append(vm.handlers, handler{frames: len(vm.frames), ip: fr.ip + offset, stack: slot})
+		case OP_END_TRY:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OP_THROW:
+			runtime.Throw(vm.pop())
+
+		default:
+			errors.RaiseRuntime(nil, fmt.Sprintf("Unknown opcode %d", op))
+		}
+	}
+}
+
+// catch hands err to the innermost error handler if it belongs to a frame
+// run from depth up: the frames above the handler's are dropped and its frame
+// continues at the handler with the caught value on the stack.
+func (vm *VM) catch(err *errors.RuntimeError, depth int) bool {
+	h, ok := vm.handler(depth)
+	if !ok {
+		return false
+	}
+
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.closeUpvalues(h.stack)
+	vm.stack = vm.stack[:h.stack]
+	vm.truncate(h.frames)
+	vm.frames[h.frames-1].ip = h.ip
+	vm.push(runtime.Caught(err))
+	return true
+}
+
+// handler returns the error handler of the innermost frame above depth that
+// has one.
+func (vm *VM) handler(depth int) (handler, bool) {
+	if len(vm.handlers) == 0 {
+		return handler{}, false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	return h, h.frames > depth
+}
+
+// trace records in the trace of err the frames above depth the error leaves,
+// and the one it is caught in, the way the tree walker does as the error
+// unwinds it. start is where the instruction of the innermost frame starts.
+func (vm *VM) trace(err *errors.RuntimeError, depth int, start int) {
+	bottom := depth
+	h, caught := vm.handler(depth)
+	if caught {
+		bottom = h.frames - 1
+	}
+
+	for i := len(vm.frames) - 1; i >= bottom; i-- {
+		fr := vm.frames[i]
+
+		// Frames below the top are at the call they made ---
+		ip := fr.ip - 1
+		if i == len(vm.frames)-1 {
+			ip = start
+		}
+		err.LocateFrame(fr.closure.Function.Chunk.Spans[ip], vm.env.Module())
+
+		if !(caught && i == bottom) && !fr.closure.Function.Script {
+			err.LeaveFunction(fr.closure.Function.Name, false)
+		}
+	}
+}
+
+// binary applies a binary operator, computing plain arithmetic on numbers
+// directly and leaving everything else to the runtime.
+func binary(operator *lexer.Token, left runtime.RuntimeValue, right runtime.RuntimeValue) runtime.RuntimeValue {
+	l, leftIsNum := left.(*runtime.NumberValue)
+	r, rightIsNum := right.(*runtime.NumberValue)
+	if !leftIsNum || !rightIsNum {
+		return runtime.BinaryOperation(operator, left, right)
+	}
+
+	switch operator.TokenType {
+	case lexer.PLUS:
+		return &runtime.NumberValue{Value: l.Value + r.Value}
+	case lexer.MINUS:
+		return &runtime.NumberValue{Value: l.Value - r.Value}
+	case lexer.STAR:
+		return &runtime.NumberValue{Value: l.Value * r.Value}
+	case lexer.SLASH:
+		if r.Value != 0 {
+			return &runtime.NumberValue{Value: l.Value / r.Value}
+		}
+	case lexer.MODULO:
+		if r.Value != 0 {
+			return &runtime.NumberValue{Value: math.Mod(l.Value, r.Value)}
+		}
+	case lexer.LESS:
+		return runtime.BOOLEAN(l.Value < r.Value)
+	case lexer.LESS_EQUAL:
+		return runtime.BOOLEAN(l.Value <= r.Value)
+	case lexer.GREATER:
+		return runtime.BOOLEAN(l.Value > r.Value)
+	case lexer.GREATER_EQUAL:
+		return runtime.BOOLEAN(l.Value >= r.Value)
+	case lexer.EQUAL_TO:
+		return runtime.BOOLEAN(l.Value == r.Value)
+	case lexer.NOT_EQUAL:
+		return runtime.BOOLEAN(l.Value != r.Value)
+	}
+
+	return runtime.BinaryOperation(operator, left, right)
+}
+
+// call calls the value sitting below the top argc values of the stack.
+// Compiled functions get a new frame; everything else runs to completion and
+// leaves its result in place of the callee and arguments.
+func (vm *VM) call(argc int) {
+	calleeSlot := len(vm.stack) - argc - 1
+
+	// Closures of other modules run on their own VM, which holds their
+	// globals, so they are called like any other callable ---
+	switch callee := vm.stack[calleeSlot].(type) {
+	case *Closure:
+		if callee.vm == vm {
+			vm.callClosure(callee, calleeSlot, argc, false)
+			return
+		}
+	case *BoundMethod:
+		if callee.Method.vm == vm {
+			vm.stack[calleeSlot] = callee.Receiver
+			vm.callClosure(callee.Method, calleeSlot, argc, false)
+			return
+		}
+	case *runtime.ClassValue:
+		if init, ok := callee.FindMethod("init"); ok {
+			if closure, ok := init.(*Closure); ok && closure.vm == vm {
+				vm.stack[calleeSlot] = runtime.INSTANCE(callee)
+				vm.callClosure(closure, calleeSlot, argc, true)
+				return
+			}
+		}
+	}
+
+	args := slices.Clone(vm.stack[calleeSlot+1:])
+	result := runtime.CallValue(vm.stack[calleeSlot], args, vm.env)
+
+	vm.stack = vm.stack[:calleeSlot]
+	vm.push(result)
+}
+
+func (vm *VM) callClosure(closure *Closure, calleeSlot int, argc int, construct bool) {
+	if argc != closure.Function.Arity {
+		errors.RaiseRuntime(nil,
+			fmt.Sprintf("Function '%s' expects %d arguments but got %d",
+				closure.Function.Name, closure.Function.Arity, argc))
+	}
+
+	// The script itself is not a call, like in the tree walker ---
+	if !closure.Function.Script {
+		vm.env.Interpreter().EnterCall()
+	}
+	vm.frames = append(vm.frames, frame{closure: closure, base: calleeSlot, construct: construct})
+}
+
+// truncate drops the frames above the first n, leaving the calls they ran.
+func (vm *VM) truncate(n int) {
+	for _, fr := range vm.frames[n:] {
+		if !fr.closure.Function.Script {
+			vm.env.Interpreter().LeaveCall()
+		}
+	}
+	vm.frames = vm.frames[:n]
+}
+
+// Upvalues ---
+
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	for i := len(vm.upvalues) - 1; i >= 0 && vm.upvalues[i].slot >= slot; i-- {
+		if vm.upvalues[i].slot == slot {
+			return vm.upvalues[i]
+		}
+	}
+
+	upvalue := &Upvalue{slot: slot, open: true}
+
+	i := len(vm.upvalues)
+	for i > 0 && vm.upvalues[i-1].slot > slot {
+		i--
+	}
+	vm.upvalues = slices.Insert(vm.upvalues, i, upvalue)
+
+	return upvalue
+}
+
+// closeUpvalues moves every variable captured from slot up off the stack.
+func (vm *VM) closeUpvalues(slot int) {
+	i := len(vm.upvalues)
+	for i > 0 && vm.upvalues[i-1].slot >= slot {
+		i--
+		upvalue := vm.upvalues[i]
+		upvalue.closed = vm.stack[upvalue.slot]
+		upvalue.open = false
+	}
+	vm.upvalues = vm.upvalues[:i]
+}
+
+func (vm *VM) upvalue(upvalue *Upvalue) runtime.RuntimeValue {
+	if upvalue.open {
+		return vm.stack[upvalue.slot]
+	}
+	return upvalue.closed
+}
+
+func (vm *VM) setUpvalue(upvalue *Upvalue, value runtime.RuntimeValue) {
+	if upvalue.open {
+		vm.stack[upvalue.slot] = value
+	} else {
+		upvalue.closed = value
+	}
+}
+
+// Stack ---
+
+func (vm *VM) push(value runtime.RuntimeValue) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() runtime.RuntimeValue {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) runtime.RuntimeValue {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// undeclared raises the error for a closure that reaches a captured variable
+// before its declaration has run.
+func undeclared(name string) {
+	errors.RaiseRuntime(nil, fmt.Sprintf("Cannot use variable \"%s\" before it is declared", name))
+}